@@ -0,0 +1,499 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix prefix of the environment variables read by LoadOptions
+const EnvPrefix = "GLOG_"
+
+// optionKey a config key and how it's applied to LoggerOptions
+type optionKey struct {
+	name string
+	set  func(opt *LoggerOptions, value string) error
+}
+
+var optionKeys = []optionKey{
+	{"level", func(opt *LoggerOptions, value string) (err error) {
+		opt.MinAllowLevel, err = logrus.ParseLevel(value)
+		return
+	}},
+	{"high_performance", func(opt *LoggerOptions, value string) (err error) {
+		opt.HighPerformance, err = strconv.ParseBool(value)
+		return
+	}},
+	{"output_dir", func(opt *LoggerOptions, value string) error {
+		opt.OutputDir = value
+		return nil
+	}},
+	{"file_prefix", func(opt *LoggerOptions, value string) error {
+		opt.FilePrefix = value
+		return nil
+	}},
+	{"save_day", func(opt *LoggerOptions, value string) error {
+		day, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if day < 0 {
+			return errors.New("must not be negative")
+		}
+		opt.SaveDay = time.Duration(day)
+		return nil
+	}},
+	{"purge_orphans", func(opt *LoggerOptions, value string) (err error) {
+		opt.PurgeOrphans, err = strconv.ParseBool(value)
+		return
+	}},
+	{"dir_quota", func(opt *LoggerOptions, value string) (err error) {
+		opt.DirQuota, err = parseSize(value)
+		return
+	}},
+	{"dir_mode", func(opt *LoggerOptions, value string) (err error) {
+		opt.DirMode, err = parseMode(value)
+		return
+	}},
+	{"file_mode", func(opt *LoggerOptions, value string) (err error) {
+		opt.FileMode, err = parseMode(value)
+		return
+	}},
+	{"owner", func(opt *LoggerOptions, value string) error {
+		opt.Owner = value
+		return nil
+	}},
+	{"group", func(opt *LoggerOptions, value string) error {
+		opt.Group = value
+		return nil
+	}},
+	{"file_pattern", func(opt *LoggerOptions, value string) error {
+		opt.FilePattern = value
+		return nil
+	}},
+	{"link_pattern", func(opt *LoggerOptions, value string) error {
+		opt.LinkPattern = value
+		return nil
+	}},
+	{"external_rotate", func(opt *LoggerOptions, value string) (err error) {
+		opt.ExternalRotate, err = strconv.ParseBool(value)
+		return
+	}},
+	{"capture_stderr", func(opt *LoggerOptions, value string) (err error) {
+		opt.CaptureStderr, err = strconv.ParseBool(value)
+		return
+	}},
+	{"sample_tick", func(opt *LoggerOptions, value string) error {
+		tick, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		if tick <= 0 {
+			return errors.New("must be positive")
+		}
+		if opt.Sampling == nil {
+			opt.Sampling = &Sampling{}
+		}
+		opt.Sampling.Tick = tick
+		return nil
+	}},
+	{"sampling", func(opt *LoggerOptions, value string) error {
+		levels, err := parseSampleRates(value)
+		if err != nil {
+			return err
+		}
+		if opt.Sampling == nil {
+			opt.Sampling = &Sampling{}
+		}
+		opt.Sampling.Levels = levels
+		return nil
+	}},
+	{"dedup_timeout", func(opt *LoggerOptions, value string) error {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		if timeout < 0 {
+			return errors.New("must not be negative")
+		}
+		opt.DedupTimeout = timeout
+		return nil
+	}},
+	{"syslog", func(opt *LoggerOptions, value string) error {
+		syslog := opt.syslog()
+		if value == "local" {
+			syslog.Network, syslog.Address = "", ""
+			return nil
+		}
+		network, address, ok := strings.Cut(value, "://")
+		if !ok || network == "" || address == "" {
+			return errors.New(`want "local" or network://address like udp://127.0.0.1:514`)
+		}
+		syslog.Network, syslog.Address = network, address
+		return nil
+	}},
+	{"syslog_facility", func(opt *LoggerOptions, value string) (err error) {
+		opt.syslog().Facility, err = ParseSyslogFacility(value)
+		return
+	}},
+	{"syslog_app_name", func(opt *LoggerOptions, value string) error {
+		opt.syslog().AppName = value
+		return nil
+	}},
+	{"journald", func(opt *LoggerOptions, value string) error {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			opt.Journald = nil
+		} else if opt.Journald == nil {
+			opt.Journald = &JournaldOptions{}
+		}
+		return nil
+	}},
+	{"time_layout", func(opt *LoggerOptions, value string) error {
+		opt.CustomTimeLayout = value
+		return nil
+	}},
+	{"formatter", func(opt *LoggerOptions, value string) (err error) {
+		opt.Formatter, err = parseFormatter(value)
+		return
+	}},
+	{"outputs_formatter", func(opt *LoggerOptions, value string) (err error) {
+		opt.ExtFormatter, err = parseFormatter(value)
+		return
+	}},
+	{"multiline", func(opt *LoggerOptions, value string) error {
+		switch value {
+		case "keep":
+			opt.Multiline = MultilineKeep
+		case "escape":
+			opt.Multiline = MultilineEscape
+		case "indent":
+			opt.Multiline = MultilineIndent
+		default:
+			return errors.New("want keep, escape or indent")
+		}
+		return nil
+	}},
+	{"continuation_marker", func(opt *LoggerOptions, value string) error {
+		opt.ContinuationMarker = value
+		return nil
+	}},
+	{"named_levels", func(opt *LoggerOptions, value string) (err error) {
+		opt.NamedLevels, err = ParseNamedLevels(value)
+		return
+	}},
+	{"outputs", func(opt *LoggerOptions, value string) error {
+		opt.ExtLoggerWriter = nil
+		for _, name := range strings.Split(value, ",") {
+			switch strings.TrimSpace(name) {
+			case "":
+			case "stdout":
+				opt.ExtLoggerWriter = append(opt.ExtLoggerWriter, os.Stdout)
+			case "stderr":
+				opt.ExtLoggerWriter = append(opt.ExtLoggerWriter, os.Stderr)
+			default:
+				return errors.Errorf("unknown output %q, want stdout or stderr", name)
+			}
+		}
+		return nil
+	}},
+}
+
+// LoadOptions build LoggerOptions from the config file at path and GLOG_* environment variables.
+// The file is JSON if its extension is ".json", YAML otherwise. An empty path reads environment
+// variables only. Precedence from low to high: defaults, config file, environment variables.
+//
+// Keys in config file, the environment variable is GLOG_ + upper case key, e.g. GLOG_OUTPUT_DIR:
+//
+//	level                trace, debug, info, warn, error, fatal or panic, default info
+//	high_performance     true or false, default false
+//	output_dir           log file dir, required
+//	file_prefix          log file name prefix
+//	save_day             days to keep the log files, default 7
+//	purge_orphans        true or false, remove log files of other prefixes out of save_day
+//	dir_quota            max total size of the log files, like 5GiB, 500MB or bytes
+//	dir_mode             octal permission of output_dir like 0750
+//	file_mode            octal permission of the log files like 0640
+//	owner                user name or uid of output_dir and the log files
+//	group                group name or gid of output_dir and the log files
+//	file_pattern         strftime pattern of the log file names, like "{name}-{host}-%Y%m%d.log"
+//	link_pattern         name of the symlinks, like "{name}.log", or "-" for no symlink
+//	external_rotate      true or false, fixed file names rotated by logrotate, reopen on SIGHUP
+//	capture_stderr       true or false, redirect stderr of the process to <prefix>-crash.log
+//	sample_tick          window of sampling like "1s", default 1s
+//	sampling             like "error=10/100,warn=5": log the first 10 errors with the same
+//	                     message per window then every 100th, the first 5 warnings only
+//	dedup_timeout        like "30s", collapse identical consecutive entries of the log files
+//	syslog               "local" for /dev/log, or network://address like udp://127.0.0.1:514,
+//	                     tcp://..., unix:///path or unixgram:///path
+//	syslog_facility      facility of syslog like local0, default user
+//	syslog_app_name      APP-NAME of syslog, default the name of the executable
+//	journald             true or false, send the entries to systemd-journald too
+//	time_layout          Go time layout of the timestamp, default RFC3339Nano
+//	formatter            text or json, default text
+//	outputs_formatter    text or json, formatter of outputs, default formatter
+//	multiline            keep, escape or indent, default keep
+//	continuation_marker  prefix of continuation lines in indent mode
+//	named_levels         like "payments=debug,gorm=warn,*=info", or a map in config file
+//	outputs              stdout and/or stderr besides log files, a list or comma separated
+//
+// Errors name the bad key (or environment variable) and where it's from.
+func LoadOptions(path string) (*LoggerOptions, error) {
+	opt, _, err := loadOptions(path)
+	return opt, err
+}
+
+// loadOptions LoadOptions, and the keys set by the config file or environment variables
+func loadOptions(path string) (*LoggerOptions, map[string]bool, error) {
+	set := map[string]bool{}
+	opt := &LoggerOptions{
+		MinAllowLevel: logrus.InfoLevel,
+		SaveDay:       7,
+	}
+	if path != "" {
+		values, err := readOptionFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, k := range optionKeys {
+			raw, ok := values[k.name]
+			if !ok {
+				continue
+			}
+			delete(values, k.name)
+			set[k.name] = true
+			v := optionValue(k.name, raw)
+			if err := k.set(opt, v); err != nil {
+				return nil, nil, errors.Errorf("[GINLOG]Invalid value %q of key %s in %s: %v", v, k.name, path, err)
+			}
+		}
+		if len(values) > 0 {
+			unknown := make([]string, 0, len(values))
+			for name := range values {
+				unknown = append(unknown, name)
+			}
+			sort.Strings(unknown)
+			return nil, nil, errors.Errorf("[GINLOG]Unknown key %s in %s", strings.Join(unknown, ", "), path)
+		}
+	}
+	for _, k := range optionKeys {
+		env := EnvPrefix + strings.ToUpper(k.name)
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := k.set(opt, v); err != nil {
+			return nil, nil, errors.Errorf("[GINLOG]Invalid value %q of environment variable %s: %v", v, env, err)
+		}
+		set[k.name] = true
+	}
+	if opt.OutputDir == "" {
+		return nil, nil, errors.Errorf("[GINLOG]Missing key output_dir (or environment variable %sOUTPUT_DIR)", EnvPrefix)
+	}
+	return opt, set, nil
+}
+
+// syslog Get opt.Syslog, it's created if nil
+func (opt *LoggerOptions) syslog() *SyslogOptions {
+	if opt.Syslog == nil {
+		opt.Syslog = &SyslogOptions{}
+	}
+	return opt.Syslog
+}
+
+// readOptionFile read the config file as key -> value, null values are left out
+func readOptionFile(path string) (map[string]interface{}, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	raw := map[string]interface{}{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		// numbers as they are written, float64 prints large ones like 5.36870912e+09
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err = dec.Decode(&raw); err == nil && dec.More() {
+			err = errors.New("invalid data after top-level value")
+		}
+	} else {
+		err = yaml.Unmarshal(b, &raw)
+	}
+	if err != nil {
+		return nil, errors.WithMessagef(err, "[GINLOG]Parse %s error", path)
+	}
+	for k, v := range raw {
+		if v == nil {
+			delete(raw, k)
+		}
+	}
+	return raw, nil
+}
+
+// octalKeys keys of permissions, a number in config file is the permission itself and is
+// written in octal, YAML reads 0640 as the number 416
+var octalKeys = map[string]bool{"dir_mode": true, "file_mode": true}
+
+// optionValue the value v of the key in config file as text
+func optionValue(key string, v interface{}) string {
+	if !octalKeys[key] {
+		return optionText(v)
+	}
+	switch n := v.(type) {
+	case int:
+		return "0" + strconv.FormatInt(int64(n), 8)
+	case int64:
+		return "0" + strconv.FormatInt(n, 8)
+	case uint64:
+		return "0" + strconv.FormatUint(n, 8)
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return "0" + strconv.FormatInt(i, 8)
+		}
+	}
+	return optionText(v)
+}
+
+// optionText flatten a config value, a list is joined by ",",
+// a map is written like "k1=v1,k2=v2" in the order of keys
+func optionText(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, optionText(item))
+		}
+		return strings.Join(items, ",")
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = item
+		}
+		return optionText(m)
+	case map[string]interface{}:
+		items := make([]string, 0, len(v))
+		for k, item := range v {
+			items = append(items, k+"="+optionText(item))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	case float64:
+		// YAML floats, not in exponent form
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parseFormatter check the formatter name
+func parseFormatter(value string) (string, error) {
+	if value != TextFormatter && value != JSONFormatter {
+		return "", errors.Errorf("want %s or %s", TextFormatter, JSONFormatter)
+	}
+	return value, nil
+}
+
+// sizeUnits suffixes of parseSize, longer first
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12}, {"B", 1},
+}
+
+// parseSize parse bytes like "5GiB", "500MB" or "1024"
+func parseSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value, unit = strings.TrimSpace(strings.TrimSuffix(value, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	if n > math.MaxInt64/unit {
+		return 0, errors.New("too large")
+	}
+	return n * unit, nil
+}
+
+// parseMode parse an octal permission like "0750"
+func parseMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimSpace(value), 8, 32)
+	if err != nil {
+		return 0, errors.New("want octal permission like 0750")
+	}
+	if mode == 0 || mode > 0777 {
+		return 0, errors.New("want permission between 0001 and 0777")
+	}
+	return os.FileMode(mode), nil
+}
+
+// parseSampleRates parse rates of levels like "error=10/100,warn=5"
+func parseSampleRates(value string) (map[logrus.Level]SampleRate, error) {
+	levels := map[logrus.Level]SampleRate{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, rate, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, errors.Errorf("want level=first/thereafter, got %q", item)
+		}
+		level, err := logrus.ParseLevel(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		first, thereafter, hasThereafter := strings.Cut(strings.TrimSpace(rate), "/")
+		var r SampleRate
+		if r.First, err = strconv.Atoi(first); err != nil || r.First < 0 {
+			return nil, errors.Errorf("invalid first count %q of level %s", first, name)
+		}
+		if hasThereafter {
+			if r.Thereafter, err = strconv.Atoi(thereafter); err != nil || r.Thereafter < 0 {
+				return nil, errors.Errorf("invalid thereafter count %q of level %s", thereafter, name)
+			}
+		}
+		levels[level] = r
+	}
+	return levels, nil
+}
@@ -0,0 +1,224 @@
+package glog
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadOptions(t *testing.T) {
+	path := writeConfig(t, "glog.yaml", `
+level: debug
+high_performance: true
+output_dir: ./logs
+file_prefix: api
+save_day: 30
+dir_quota: 5GiB
+dir_mode: "0750"
+file_mode: "0640"
+time_layout: "2006/01/02 15:04:05"
+formatter: json
+multiline: indent
+named_levels:
+  payments: debug
+  gorm: warn
+outputs: [stdout, stderr]
+`)
+	opt, err := LoadOptions(path)
+	assert.Nil(t, err)
+	assert.Equal(t, &LoggerOptions{
+		MinAllowLevel:    logrus.DebugLevel,
+		HighPerformance:  true,
+		OutputDir:        "./logs",
+		FilePrefix:       "api",
+		SaveDay:          30,
+		DirQuota:         5 << 30,
+		DirMode:          0750,
+		FileMode:         0640,
+		ExtLoggerWriter:  []io.Writer{os.Stdout, os.Stderr},
+		CustomTimeLayout: "2006/01/02 15:04:05",
+		Multiline:        MultilineIndent,
+		NamedLevels:      map[string]logrus.Level{"payments": logrus.DebugLevel, "gorm": logrus.WarnLevel},
+		Formatter:        JSONFormatter,
+	}, opt)
+
+	// environment variables take precedence
+	t.Setenv("GLOG_LEVEL", "warn")
+	t.Setenv("GLOG_OUTPUTS", "")
+	t.Setenv("GLOG_NAMED_LEVELS", "*=error")
+	opt, err = LoadOptions(path)
+	assert.Nil(t, err)
+	assert.Equal(t, logrus.WarnLevel, opt.MinAllowLevel)
+	assert.Nil(t, opt.ExtLoggerWriter)
+	assert.Equal(t, map[string]logrus.Level{"*": logrus.ErrorLevel}, opt.NamedLevels)
+	assert.Equal(t, "api", opt.FilePrefix)
+}
+
+func TestLoadOptionsJSON(t *testing.T) {
+	path := writeConfig(t, "glog.json", `{"output_dir": "./logs", "save_day": 3, "named_levels": "gin=warn"}`)
+	opt, err := LoadOptions(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "./logs", opt.OutputDir)
+	assert.Equal(t, logrus.InfoLevel, opt.MinAllowLevel)
+	assert.EqualValues(t, 3, opt.SaveDay)
+	assert.Equal(t, map[string]logrus.Level{"gin": logrus.WarnLevel}, opt.NamedLevels)
+
+	// numbers are not read in exponent form
+	path = writeConfig(t, "numbers.json", `{"output_dir": "./logs", "dir_quota": 5368709120, "save_day": 1000000}`)
+	opt, err = LoadOptions(path)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 5<<30, opt.DirQuota)
+	assert.EqualValues(t, 1000000, opt.SaveDay)
+	opt, err = LoadOptions(writeConfig(t, "numbers.yaml", "output_dir: ./logs\ndir_quota: 1.0e+6\n"))
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1000000, opt.DirQuota)
+	_, err = LoadOptions(writeConfig(t, "trailing.json", `{"output_dir": "./logs"} {}`))
+	assert.Error(t, err)
+
+	// environment variables only
+	t.Setenv("GLOG_OUTPUT_DIR", "./env-logs")
+	opt, err = LoadOptions("")
+	assert.Nil(t, err)
+	assert.Equal(t, "./env-logs", opt.OutputDir)
+	assert.EqualValues(t, 7, opt.SaveDay)
+}
+
+func TestLoadOptionsError(t *testing.T) {
+	_, err := LoadOptions(writeConfig(t, "bad-level.yaml", "output_dir: ./logs\nlevel: verbose\n"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "key level")
+
+	_, err = LoadOptions(writeConfig(t, "unknown.yaml", "output_dir: ./logs\nlevle: debug\nsave_days: 7\n"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Unknown key levle, save_days in")
+
+	_, err = LoadOptions(writeConfig(t, "bad.json", `{"output_dir": "./logs", "outputs": ["stdout", "file"]}`))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "key outputs")
+
+	_, err = LoadOptions(writeConfig(t, "missing.yaml", "level: debug\n"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "output_dir")
+
+	_, err = LoadOptions(writeConfig(t, "float.json", `{"output_dir": "./logs", "dir_quota": 1.5}`))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `"1.5" of key dir_quota`)
+
+	// YAML reads 640 as a decimal number, it's not a permission
+	_, err = LoadOptions(writeConfig(t, "mode.yaml", "output_dir: ./logs\nfile_mode: 640\n"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "key file_mode")
+
+	t.Setenv("GLOG_SAVE_DAY", "a week")
+	_, err = LoadOptions(writeConfig(t, "ok.yaml", "output_dir: ./logs\n"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "GLOG_SAVE_DAY")
+}
+
+func TestLoadOptionsMode(t *testing.T) {
+	// unquoted octal numbers of YAML
+	opt, err := LoadOptions(writeConfig(t, "glog.yaml", "output_dir: ./logs\ndir_mode: 0750\nfile_mode: 0640\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0750), opt.DirMode)
+	assert.Equal(t, os.FileMode(0640), opt.FileMode)
+
+	// JSON has no octal numbers, a number is the permission itself
+	opt, err = LoadOptions(writeConfig(t, "glog.json", `{"output_dir": "./logs", "dir_mode": 488, "file_mode": "0640"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0750), opt.DirMode)
+	assert.Equal(t, os.FileMode(0640), opt.FileMode)
+}
+
+func TestParseSize(t *testing.T) {
+	for value, want := range map[string]int64{"1024": 1024, "5GiB": 5 << 30, "500 MB": 500e6, "10B": 10} {
+		n, err := parseSize(value)
+		assert.Nil(t, err)
+		assert.Equal(t, want, n)
+	}
+	_, err := parseSize("5PiB")
+	assert.Error(t, err)
+	_, err = parseSize("-1")
+	assert.Error(t, err)
+	_, err = parseSize("9999999999GiB")
+	assert.Error(t, err)
+	n, err := parseSize("8388607TiB")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8388607)<<40, n)
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := parseMode("0750")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0750), mode)
+	mode, err = parseMode("640")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), mode)
+	for _, value := range []string{"rwx", "0", "0888", "01777"} {
+		_, err = parseMode(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseSampleRates(t *testing.T) {
+	levels, err := parseSampleRates("error=10/100, warn=5")
+	assert.Nil(t, err)
+	assert.Equal(t, map[logrus.Level]SampleRate{
+		logrus.ErrorLevel: {First: 10, Thereafter: 100},
+		logrus.WarnLevel:  {First: 5},
+	}, levels)
+	for _, value := range []string{"error", "verbose=1", "error=a/1", "error=1/-1"} {
+		_, err = parseSampleRates(value)
+		assert.Error(t, err, value)
+	}
+
+	// a map in config file
+	opt, err := LoadOptions(writeConfig(t, "sampling.yaml", `
+output_dir: ./logs
+sample_tick: 5s
+sampling:
+  error: 10/100
+dedup_timeout: 30s
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, opt.DedupTimeout)
+	assert.Equal(t, &Sampling{Tick: 5 * time.Second, Levels: map[logrus.Level]SampleRate{
+		logrus.ErrorLevel: {First: 10, Thereafter: 100},
+	}}, opt.Sampling)
+}
+
+func TestLoadOptionsSinks(t *testing.T) {
+	opt, err := LoadOptions(writeConfig(t, "sinks.yaml", `
+output_dir: ./logs
+syslog: udp://127.0.0.1:514
+syslog_facility: local0
+syslog_app_name: api
+journald: true
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, &SyslogOptions{Network: "udp", Address: "127.0.0.1:514", Facility: FacilityLocal0, AppName: "api"},
+		opt.Syslog)
+	assert.Equal(t, &JournaldOptions{}, opt.Journald)
+
+	t.Setenv("GLOG_SYSLOG", "local")
+	t.Setenv("GLOG_JOURNALD", "false")
+	opt, err = LoadOptions(writeConfig(t, "sinks.yaml", "output_dir: ./logs\nsyslog: udp://127.0.0.1:514\njournald: true\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, &SyslogOptions{}, opt.Syslog)
+	assert.Nil(t, opt.Journald)
+
+	for _, value := range []string{"127.0.0.1:514", "udp://"} {
+		t.Setenv("GLOG_SYSLOG", value)
+		_, err = LoadOptions(writeConfig(t, "ok.yaml", "output_dir: ./logs\n"))
+		assert.Error(t, err, value)
+	}
+}
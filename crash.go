@@ -0,0 +1,81 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package glog
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// crashFile stderr of the process after captureStderr, kept open until exit
+var crashFile *os.File
+var crashMu sync.Mutex
+
+// crashFileName name of the file capturing stderr in OutputDir
+func (opt *LoggerOptions) crashFileName() string {
+	if opt.FilePrefix == "" {
+		return filepath.Join(opt.OutputDir, "crash.log")
+	}
+	return filepath.Join(opt.OutputDir, opt.FilePrefix+"-crash.log")
+}
+
+// captureStderr redirect stderr of the process, including the crash reports written
+// by the Go runtime, to the crash file. It's done once, later calls do nothing.
+func captureStderr(opt *LoggerOptions) error {
+	crashMu.Lock()
+	defer crashMu.Unlock()
+	if crashFile != nil {
+		return nil
+	}
+	mode := opt.FileMode
+	if mode == 0 {
+		mode = 0644
+	}
+	f, err := os.OpenFile(opt.crashFileName(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, mode)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// mark where the output of this process starts
+	_, _ = fmt.Fprintf(f, "==== %s process %d started, stderr is redirected here ====\n",
+		time.Now().Format(time.RFC3339), os.Getpid())
+	if err := dupStderr(f); err != nil {
+		_ = f.Close()
+		return errors.WithMessage(err, "redirect stderr error")
+	}
+	crashFile = f
+	return nil
+}
+
+// Go run fn in a new goroutine. If it panics, the panic and its stack are logged at ErrorLevel
+// by the global logger before panicking again, so the crash is in the error log even if
+// stderr is lost.
+func Go(fn func()) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				ShareLogger().Errorf("[GINLOG]Panic in goroutine: %v\n%s", r, debug.Stack())
+				panic(r)
+			}
+		}()
+		fn()
+	}()
+}
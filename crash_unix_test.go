@@ -0,0 +1,53 @@
+//go:build unix
+
+package glog
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGo_CaptureStderr(t *testing.T) {
+	// the panic crashes the process, run it in a child process
+	if dir := os.Getenv("GLOG_CRASH_DIR"); dir != "" {
+		err := InitGlobalLogger(&LoggerOptions{
+			MinAllowLevel: logrus.InfoLevel,
+			OutputDir:     dir,
+			FilePrefix:    "crash",
+			CaptureStderr: true,
+		})
+		if err != nil {
+			panic(err)
+		}
+		Go(func() {
+			panic("boom")
+		})
+		time.Sleep(10 * time.Second)
+		return
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestGo_CaptureStderr$")
+	cmd.Env = append(os.Environ(), "GLOG_CRASH_DIR="+dir)
+	out, err := cmd.CombinedOutput()
+	assert.NotNil(t, err)
+	// stderr of the child went to the crash file
+	assert.NotContains(t, string(out), "panic: boom")
+
+	crash, err := os.ReadFile(filepath.Join(dir, "crash-crash.log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(crash), "stderr is redirected here")
+	assert.Contains(t, string(crash), "panic: boom")
+	assert.Contains(t, string(crash), "goroutine ")
+
+	// the panic is logged with its stack before crashing
+	errorLog, err := os.ReadFile(filepath.Join(dir, "latest-error-crash-log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(errorLog), "Panic in goroutine: boom")
+	assert.Contains(t, string(errorLog), "runtime/debug.Stack")
+}
@@ -0,0 +1,132 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package glog
+
+import (
+	"github.com/gin-melodic/glog/internal/formatter"
+	"github.com/sirupsen/logrus"
+	"math"
+	"time"
+)
+
+// Field a typed key value pair of an entry, create it by String, Int, Err, ...
+type Field = formatter.Field
+
+// Fields typed fields kept in entry.Data[FieldsKey]
+type Fields = formatter.Fields
+
+// FieldsKey entry.Data key of the typed fields. TextFormatter writes them as " k=v" after the message,
+// JSONFormatter as an object of this key, e.g. "fields":{"user":"alice","n":3}.
+const FieldsKey = formatter.FieldsKey
+
+// String field of string value
+func String(key, value string) Field {
+	return Field{Key: key, Type: formatter.StringType, String: value}
+}
+
+// Int field of int value
+func Int(key string, value int) Field {
+	return Int64(key, int64(value))
+}
+
+// Int64 field of int64 value
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Type: formatter.IntType, Integer: value}
+}
+
+// Uint64 field of uint64 value
+func Uint64(key string, value uint64) Field {
+	return Field{Key: key, Type: formatter.UintType, Integer: int64(value)}
+}
+
+// Bool field of bool value
+func Bool(key string, value bool) Field {
+	var i int64
+	if value {
+		i = 1
+	}
+	return Field{Key: key, Type: formatter.BoolType, Integer: i}
+}
+
+// Float64 field of float64 value
+func Float64(key string, value float64) Field {
+	return Field{Key: key, Type: formatter.Float64Type, Integer: int64(math.Float64bits(value))}
+}
+
+// Duration field of time.Duration value, written like "1.5s"
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Type: formatter.DurationType, Integer: int64(value)}
+}
+
+// Time field of time.Time value, written in the time layout of the formatter
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, Type: formatter.TimeType, Integer: value.UnixNano(), Interface: value.Location()}
+}
+
+// Err field of key "error", errors of github.com/pkg/errors print their stack trace like WithError
+func Err(err error) Field {
+	return Field{Key: logrus.ErrorKey, Type: formatter.ErrorType, Interface: err}
+}
+
+// Any field of any value, written by fmt or encoding/json, prefer the typed ones
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Type: formatter.AnyType, Interface: value}
+}
+
+// Trace log msg with typed fields at TraceLevel by the global logger
+func Trace(msg string, fields ...Field) {
+	ShareLogger().LogFields(1, logrus.TraceLevel, msg, fields)
+}
+
+// Debug log msg with typed fields at DebugLevel by the global logger
+func Debug(msg string, fields ...Field) {
+	ShareLogger().LogFields(1, logrus.DebugLevel, msg, fields)
+}
+
+// Info log msg with typed fields at InfoLevel by the global logger, e.g.
+//
+//	glog.Info("order paid", glog.String("user", u), glog.Int("items", n), glog.Duration("cost", d))
+func Info(msg string, fields ...Field) {
+	ShareLogger().LogFields(1, logrus.InfoLevel, msg, fields)
+}
+
+// Warn log msg with typed fields at WarnLevel by the global logger
+func Warn(msg string, fields ...Field) {
+	ShareLogger().LogFields(1, logrus.WarnLevel, msg, fields)
+}
+
+// Error log msg with typed fields at ErrorLevel by the global logger
+func Error(msg string, fields ...Field) {
+	ShareLogger().LogFields(1, logrus.ErrorLevel, msg, fields)
+}
+
+// With Get an entry of the global logger carrying typed fields, to use them with logrus API:
+//
+//	glog.With(glog.String("user", u)).WithField("legacy", v).Infof("paid %d", n)
+func With(fields ...Field) *logrus.Entry {
+	return AddFields(logrus.NewEntry(ShareLogger().Logger), fields...)
+}
+
+// AddFields Get a copy of entry with fields appended to its typed fields, e.g. on a named logger:
+//
+//	glog.AddFields(glog.Named("payments"), glog.Int("order", id)).Info("paid")
+func AddFields(entry *logrus.Entry, fields ...Field) *logrus.Entry {
+	old, _ := entry.Data[FieldsKey].(Fields)
+	all := make(Fields, 0, len(old)+len(fields))
+	all = append(append(all, old...), fields...)
+	return entry.WithField(FieldsKey, all)
+}
@@ -0,0 +1,45 @@
+package glog
+
+import (
+	"bytes"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTypedFields(t *testing.T) {
+	var out bytes.Buffer
+	l, err := NewLoggerHandle(&LoggerOptions{
+		MinAllowLevel:   logrus.InfoLevel,
+		OutputDir:       t.TempDir(),
+		ExtLoggerWriter: []io.Writer{&out},
+	})
+	assert.Nil(t, err)
+	defer ReplaceGlobal(l)()
+	defer l.Close()
+
+	Debug("hidden", String("k", "v"))
+	assert.Empty(t, out.String())
+	_, _, line, _ := runtime.Caller(0)
+	Info("order paid",
+		String("user", "alice"),
+		Int("items", 3),
+		Bool("vip", true),
+		Float64("amount", 9.5),
+		Duration("cost", 1500*time.Millisecond),
+		Err(errors.New("retried")))
+	assert.Contains(t, out.String(), "[field_test.go:"+strconv.Itoa(line+1)+"][INFO]order paid user=alice items=3 vip=true amount=9.5 cost=1.5s error=retried\n")
+
+	// interoperable with logrus API
+	out.Reset()
+	AddFields(With(String("user", "bob")), Int("n", 1)).WithField("legacy", true).Warnf("paid %d", 2)
+	assert.Contains(t, out.String(), "[WARNING]paid 2 user=bob n=1\n")
+	out.Reset()
+	AddFields(Named("payments"), Uint64("order", 7)).Error("failed")
+	assert.Contains(t, out.String(), "[ERROR][payments]failed order=7\n")
+}
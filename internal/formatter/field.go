@@ -0,0 +1,252 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldsKey entry.Data key of the typed fields, a Fields value.
+// Formatters of this package encode them one by one, other formatters and hooks
+// see a single value which is a JSON object and a "k=v k=v" string.
+const FieldsKey = "fields"
+
+// FieldType how the value of a Field is stored
+type FieldType uint8
+
+const (
+	// AnyType value in Interface, encoded by fmt or encoding/json
+	AnyType FieldType = iota
+	StringType
+	// IntType, UintType, BoolType, Float64Type, DurationType value in Integer
+	IntType
+	UintType
+	BoolType
+	Float64Type
+	DurationType
+	// TimeType unix nano in Integer, *time.Location in Interface
+	TimeType
+	// ErrorType error in Interface
+	ErrorType
+)
+
+// Field a typed key value pair, the value is stored without boxing when possible
+type Field struct {
+	Key       string
+	Type      FieldType
+	Integer   int64
+	String    string
+	Interface interface{}
+}
+
+// Value Get value of the field as interface{}
+func (f *Field) Value() interface{} {
+	switch f.Type {
+	case StringType:
+		return f.String
+	case IntType:
+		return f.Integer
+	case UintType:
+		return uint64(f.Integer)
+	case BoolType:
+		return f.Integer == 1
+	case Float64Type:
+		return math.Float64frombits(uint64(f.Integer))
+	case DurationType:
+		return time.Duration(f.Integer)
+	case TimeType:
+		return f.time()
+	default:
+		return f.Interface
+	}
+}
+
+func (f *Field) time() time.Time {
+	t := time.Unix(0, f.Integer)
+	if loc, ok := f.Interface.(*time.Location); ok {
+		t = t.In(loc)
+	}
+	return t
+}
+
+// Fields typed fields of an entry, in the order they are given
+type Fields []Field
+
+// appendText write the fields like " k=v k2=v2" into buf, values with spaces or quotes are quoted
+func (fs Fields) appendText(buf *bytes.Buffer, layout string) {
+	var scratch [64]byte
+	for i := range fs {
+		f := &fs[i]
+		buf.WriteByte(' ')
+		buf.WriteString(f.Key)
+		buf.WriteByte('=')
+		switch f.Type {
+		case StringType:
+			writeTextString(buf, f.String)
+		case IntType:
+			buf.Write(strconv.AppendInt(scratch[:0], f.Integer, 10))
+		case UintType:
+			buf.Write(strconv.AppendUint(scratch[:0], uint64(f.Integer), 10))
+		case BoolType:
+			buf.Write(strconv.AppendBool(scratch[:0], f.Integer == 1))
+		case Float64Type:
+			buf.Write(strconv.AppendFloat(scratch[:0], math.Float64frombits(uint64(f.Integer)), 'g', -1, 64))
+		case DurationType:
+			buf.WriteString(time.Duration(f.Integer).String())
+		case TimeType:
+			buf.Write(f.time().AppendFormat(scratch[:0], layout))
+		case ErrorType:
+			if err, ok := f.Interface.(error); ok && err != nil {
+				writeTextString(buf, err.Error())
+			} else {
+				buf.WriteString("<nil>")
+			}
+		default:
+			writeTextString(buf, fmt.Sprint(f.Interface))
+		}
+	}
+}
+
+// writeTextString write s, quoted if it's empty or has spaces, quotes, '=' or control characters
+func writeTextString(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		if r <= ' ' || r == '"' || r == '=' || r == utf8.RuneError || r == 0x7f {
+			var scratch [64]byte
+			buf.Write(strconv.AppendQuote(scratch[:0], s))
+			return
+		}
+	}
+	if s == "" {
+		buf.WriteString(`""`)
+		return
+	}
+	buf.WriteString(s)
+}
+
+// String the fields like "k=v k2=v2", used by the formatters of logrus
+func (fs Fields) String() string {
+	var buf bytes.Buffer
+	fs.appendText(&buf, time.RFC3339Nano)
+	if buf.Len() == 0 {
+		return ""
+	}
+	return buf.String()[1:]
+}
+
+// MarshalJSON the fields as a JSON object, a later field overrides the earlier one with the same key
+func (fs Fields) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := fs.appendJSON(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (fs Fields) appendJSON(buf *bytes.Buffer) error {
+	var scratch [64]byte
+	buf.WriteByte('{')
+	first := true
+	for i := range fs {
+		f := &fs[i]
+		if fs.overridden(i) {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.Write(strconv.AppendQuote(scratch[:0], f.Key))
+		buf.WriteByte(':')
+		switch f.Type {
+		case StringType:
+			buf.Write(strconv.AppendQuote(scratch[:0], f.String))
+		case IntType:
+			buf.Write(strconv.AppendInt(scratch[:0], f.Integer, 10))
+		case UintType:
+			buf.Write(strconv.AppendUint(scratch[:0], uint64(f.Integer), 10))
+		case BoolType:
+			buf.Write(strconv.AppendBool(scratch[:0], f.Integer == 1))
+		case Float64Type:
+			x := math.Float64frombits(uint64(f.Integer))
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				// not valid JSON numbers
+				buf.Write(strconv.AppendQuote(scratch[:0], strconv.FormatFloat(x, 'g', -1, 64)))
+			} else {
+				buf.Write(strconv.AppendFloat(scratch[:0], x, 'g', -1, 64))
+			}
+		case DurationType:
+			buf.Write(strconv.AppendQuote(scratch[:0], time.Duration(f.Integer).String()))
+		case TimeType:
+			buf.WriteByte('"')
+			buf.Write(f.time().AppendFormat(scratch[:0], time.RFC3339Nano))
+			buf.WriteByte('"')
+		case ErrorType:
+			if err, ok := f.Interface.(error); ok && err != nil {
+				buf.Write(strconv.AppendQuote(scratch[:0], err.Error()))
+			} else {
+				buf.WriteString("null")
+			}
+		default:
+			b, err := json.Marshal(f.Interface)
+			if err != nil {
+				return err
+			}
+			buf.Write(b)
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// overridden report whether a later field has the same key as the field i, like logrus.Fields
+// only the last one is kept in JSON
+func (fs Fields) overridden(i int) bool {
+	for k := i + 1; k < len(fs); k++ {
+		if fs[k].Key == fs[i].Key {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldsOf Get the typed fields in entry data
+func fieldsOf(data map[string]interface{}) Fields {
+	fs, _ := data[FieldsKey].(Fields)
+	return fs
+}
+
+// errorOf Get the error of logrus.ErrorKey in data, or of the last typed field of ErrorType
+func errorOf(data map[string]interface{}) (error, bool) {
+	if err, ok := data[logrus.ErrorKey].(error); ok {
+		return err, true
+	}
+	fs := fieldsOf(data)
+	for i := len(fs) - 1; i >= 0; i-- {
+		if fs[i].Type == ErrorType {
+			err, ok := fs[i].Interface.(error)
+			return err, ok && err != nil
+		}
+	}
+	return nil, false
+}
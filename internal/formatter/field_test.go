@@ -0,0 +1,94 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFields() Fields {
+	ts := time.Date(2021, 5, 1, 8, 30, 0, 0, time.UTC)
+	return Fields{
+		{Key: "user", Type: StringType, String: "alice"},
+		{Key: "note", Type: StringType, String: "two words"},
+		{Key: "n", Type: IntType, Integer: -3},
+		{Key: "u", Type: UintType, Integer: 7},
+		{Key: "ok", Type: BoolType, Integer: 1},
+		{Key: "ratio", Type: Float64Type, Integer: int64(math.Float64bits(0.5))},
+		{Key: "cost", Type: DurationType, Integer: int64(1500 * time.Millisecond)},
+		{Key: "at", Type: TimeType, Integer: ts.UnixNano(), Interface: time.UTC},
+		{Key: "error", Type: ErrorType, Interface: errors.New("failed")},
+		{Key: "tags", Type: AnyType, Interface: []string{"a", "b"}},
+	}
+}
+
+func TestFields_Text(t *testing.T) {
+	var buf bytes.Buffer
+	testFields().appendText(&buf, time.RFC3339)
+	assert.Equal(t, ` user=alice note="two words" n=-3 u=7 ok=true ratio=0.5 cost=1.5s at=2021-05-01T08:30:00Z error=failed tags="[a b]"`, buf.String())
+	assert.Equal(t, `empty="" line="a\nb"`, Fields{
+		{Key: "empty", Type: StringType},
+		{Key: "line", Type: StringType, String: "a\nb"},
+	}.String())
+}
+
+func TestFields_JSON(t *testing.T) {
+	b, err := json.Marshal(testFields())
+	assert.Nil(t, err)
+	assert.Equal(t, `{"user":"alice","note":"two words","n":-3,"u":7,"ok":true,"ratio":0.5,"cost":"1.5s",`+
+		`"at":"2021-05-01T08:30:00Z","error":"failed","tags":["a","b"]}`, string(b))
+
+	// the last one of the same key wins, and NaN is not a JSON number
+	b, err = json.Marshal(Fields{
+		{Key: "k", Type: IntType, Integer: 1},
+		{Key: "x", Type: Float64Type, Integer: int64(math.Float64bits(math.NaN()))},
+		{Key: "k", Type: IntType, Integer: 2},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"x":"NaN","k":2}`, string(b))
+
+	// with the logrus formatter
+	entry := &logrus.Entry{Level: logrus.InfoLevel, Message: "m", Data: logrus.Fields{FieldsKey: testFields()[:1]}}
+	b, err = (&logrus.JSONFormatter{}).Format(entry)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `"fields":{"user":"alice"}`)
+}
+
+func TestFormatter_Fields(t *testing.T) {
+	entry := &logrus.Entry{
+		Level:   logrus.ErrorLevel,
+		Message: "paid",
+		Data: logrus.Fields{FieldsKey: Fields{
+			{Key: "user", Type: StringType, String: "alice"},
+			{Key: "error", Type: ErrorType, Interface: errors.New("with stack")},
+		}},
+	}
+	var f Formatter
+	b, err := f.Format(entry)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "[ERROR]paid user=alice error=\"with stack\"\n"+stackBegin+"\n")
+	assert.True(t, strings.HasSuffix(string(b), stackEnd+"\n"))
+	assert.Equal(t, "alice", entry.Data[FieldsKey].(Fields)[0].Value())
+}
@@ -17,9 +17,13 @@ limitations under the License.
 package formatter
 
 import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -51,4 +55,137 @@ func TestFormatter_Format(t *testing.T) {
 	assert.Nil(t, err)
 	println(string(b))
 	assert.Contains(t, string(b), "[test.go:11211]")	// check caller
-}
\ No newline at end of file
+}
+
+func TestFormatter_Multiline(t *testing.T) {
+	entry := &logrus.Entry{
+		Level:   logrus.InfoLevel,
+		Message: "SELECT *\nFROM t\r\nWHERE id = 1",
+	}
+	// keep
+	var f Formatter
+	b, err := f.Format(entry)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "SELECT *\nFROM t\r\nWHERE id = 1\n")
+
+	// escape
+	f.Multiline = MultilineEscape
+	b, err = f.Format(entry)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, strings.Count(string(b), "\n"))
+	assert.Contains(t, string(b), `SELECT *\nFROM t\r\nWHERE id = 1`)
+
+	// indent
+	f.Multiline = MultilineIndent
+	f.ContinuationMarker = "  > "
+	b, err = f.Format(entry)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "SELECT *\n  > FROM t\n  > WHERE id = 1\n")
+}
+
+func TestFormatter_Stack(t *testing.T) {
+	entry := &logrus.Entry{
+		Level:   logrus.ErrorLevel,
+		Message: "query failed",
+		Data:    logrus.Fields{logrus.ErrorKey: errors.WithMessage(errors.New("no such table"), "exec")},
+	}
+	f := Formatter{Multiline: MultilineEscape}
+	b, err := f.Format(entry)
+	assert.Nil(t, err)
+	println(string(b))
+	bStr := string(b)
+	assert.Equal(t, 1, strings.Count(bStr, "\n"))
+	assert.Contains(t, bStr, `query failed\n>>> STACK\nno such table\n`)
+	assert.Contains(t, bStr, "TestFormatter_Stack")
+	assert.True(t, strings.HasSuffix(bStr, `\n<<< STACK END`+"\n"))
+
+	// error without stack trace prints no block
+	entry.Data[logrus.ErrorKey] = fmt.Errorf("plain")
+	b, err = f.Format(entry)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), "STACK")
+}
+
+func TestGetPid(t *testing.T) {
+	pid, err := getPid()
+	assert.Nil(t, err)
+	assert.NotZero(t, pid)
+	if raceEnabled {
+		t.Skip("allocations are not counted with the race detector")
+	}
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		_, _ = getPid()
+	}))
+}
+
+func TestFormatter_Allocs(t *testing.T) {
+	entry := &logrus.Entry{
+		Time:    time.Now(),
+		Level:   logrus.InfoLevel,
+		Caller:  &runtime.Frame{File: "/src/main.go", Line: 42},
+		Logger:  &logrus.Logger{ReportCaller: true},
+		Data:    logrus.Fields{NameKey: "payments"},
+		Message: "logger content",
+		Buffer:  &bytes.Buffer{},
+	}
+	f := Formatter{Multiline: MultilineEscape}
+	b, err := f.Format(entry)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "[PID:")
+	assert.Contains(t, string(b), "[main.go:42][INFO][payments]logger content\n")
+	assert.True(t, strings.HasPrefix(string(b), entry.Time.Local().Format(time.RFC3339Nano)+" "))
+
+	if raceEnabled {
+		t.Skip("allocations are not counted with the race detector")
+	}
+	// logged by logrus with entry.Buffer, nothing is allocated
+	allocs := testing.AllocsPerRun(100, func() {
+		entry.Buffer.Reset()
+		_, _ = f.Format(entry)
+	})
+	assert.Zero(t, allocs)
+	// without entry.Buffer, only the returned bytes
+	entry.Buffer = nil
+	allocs = testing.AllocsPerRun(100, func() {
+		_, _ = f.Format(entry)
+	})
+	assert.Equal(t, float64(1), allocs)
+}
+
+func BenchmarkFormatter_Format(b *testing.B) {
+	entry := &logrus.Entry{
+		Time:    time.Now(),
+		Level:   logrus.InfoLevel,
+		Message: "benchmark entry",
+		Buffer:  &bytes.Buffer{},
+	}
+	var f Formatter
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		entry.Buffer.Reset()
+		_, _ = f.Format(entry)
+	}
+}
+
+func BenchmarkFormatter_FormatWithCaller(b *testing.B) {
+	entry := &logrus.Entry{
+		Time:    time.Now(),
+		Level:   logrus.InfoLevel,
+		Caller:  &runtime.Frame{File: "/src/main.go", Line: 42},
+		Logger:  &logrus.Logger{ReportCaller: true},
+		Data:    logrus.Fields{NameKey: "payments"},
+		Message: "benchmark entry",
+	}
+	var f Formatter
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = f.Format(entry)
+	}
+}
+
+func BenchmarkGetPid(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = getPid()
+	}
+}
@@ -18,66 +18,222 @@ package formatter
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// MultilineMode decide how line breaks inside a log message are written
+type MultilineMode uint8
+
+const (
+	// MultilineKeep write line breaks as they are, this is the default mode
+	MultilineKeep MultilineMode = iota
+	// MultilineEscape replace line breaks with `\n` (and `\r`), every entry takes exactly one line
+	MultilineEscape
+	// MultilineIndent start every continuation line with the ContinuationMarker
+	MultilineIndent
+)
+
+// DefaultContinuationMarker continuation line prefix in MultilineIndent mode
+const DefaultContinuationMarker = "\t| "
+
+// NameKey field key of the logger name, see setup.Logger.Named
+const NameKey = "logger"
+
+const (
+	stackBegin = ">>> STACK"
+	stackEnd   = "<<< STACK END"
+)
+
 type Formatter struct {
 	// timestamp layout, default is RFC3339Nano
 	TimeStampLayout string
+	// Multiline how to deal with line breaks in message, default is MultilineKeep
+	Multiline MultilineMode
+	// ContinuationMarker prefix of continuation lines in MultilineIndent mode,
+	// default is DefaultContinuationMarker
+	ContinuationMarker string
+}
+
+// stackTracer implemented by errors created with github.com/pkg/errors
+type stackTracer interface {
+	StackTrace() errors.StackTrace
 }
 
-// Format extend logrus.Formatter, format logger content
+// levelNames upper case names of the levels, indexed by logrus.Level
+var levelNames = func() []string {
+	names := make([]string, len(logrus.AllLevels))
+	for _, level := range logrus.AllLevels {
+		names[level] = strings.ToUpper(level.String())
+	}
+	return names
+}()
+
+// bufferPool buffers of the entries formatted without entry.Buffer
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// Format extend logrus.Formatter, format logger content.
+// It writes into entry.Buffer if it's set by logrus, otherwise into a pooled buffer
+// and returns a copy, the fast path allocates nothing else.
 func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if entry.Buffer != nil {
+		if err := f.format(entry.Buffer, entry); err != nil {
+			return nil, err
+		}
+		return entry.Buffer.Bytes(), nil
+	}
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer bufferPool.Put(buf)
+	buf.Reset()
+	if err := f.format(buf, entry); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+func (f *Formatter) format(msg *bytes.Buffer, entry *logrus.Entry) error {
 	layout := time.RFC3339Nano
 	if f.TimeStampLayout != "" {
 		layout = f.TimeStampLayout
 	}
-	ts := time.Now().Local().Format(layout)
+	t := entry.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
 	pid, err := getPid()
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return errors.WithStack(err)
 	}
-	// for good performance, see https://github.com/hatlonely/hellogolang/blob/master/internal/buildin/string_test.go
-	var msg bytes.Buffer
+	// append into the spare capacity of msg, it allocates only when msg grows
+	var scratch [64]byte
 	// timestamp
-	msg.WriteString(ts)
+	msg.Write(t.Local().AppendFormat(scratch[:0], layout))
 	// padding
 	msg.WriteByte(' ')
 	// pid
 	msg.WriteString("[PID:")
-	msg.WriteString(strconv.Itoa(int(pid)))
+	msg.Write(strconv.AppendUint(scratch[:0], pid, 10))
 	msg.WriteByte(']')
 	if entry.HasCaller() {
 		// log with caller info
 		msg.WriteByte('[')
 		msg.WriteString(filepath.Base(entry.Caller.File))
 		msg.WriteByte(':')
-		msg.WriteString(strconv.Itoa(entry.Caller.Line))
+		msg.Write(strconv.AppendInt(scratch[:0], int64(entry.Caller.Line), 10))
 		msg.WriteByte(']')
 	}
 	// level
 	msg.WriteByte('[')
-	msg.WriteString(strings.ToUpper(entry.Level.String()))
+	if int(entry.Level) < len(levelNames) {
+		msg.WriteString(levelNames[entry.Level])
+	} else {
+		msg.WriteString(strings.ToUpper(entry.Level.String()))
+	}
 	msg.WriteByte(']')
+	// logger name
+	if name, ok := entry.Data[NameKey].(string); ok && name != "" {
+		msg.WriteByte('[')
+		msg.WriteString(name)
+		msg.WriteByte(']')
+	}
 	// logger content
-	msg.WriteString(entry.Message)
+	f.writeMultiline(msg, entry.Message)
+	// typed fields
+	if fs := fieldsOf(entry.Data); len(fs) > 0 {
+		fs.appendText(msg, layout)
+	}
+	// stack trace of github.com/pkg/errors error
+	if err, ok := errorOf(entry.Data); ok && hasStack(err) {
+		f.writeMultiline(msg, "\n"+stackBegin+"\n"+strings.TrimRight(fmt.Sprintf("%+v", err), "\n")+"\n"+stackEnd)
+	}
 	msg.WriteByte('\n')
-	return msg.Bytes(), nil
+	return nil
+}
+
+// writeMultiline write s into buf, line breaks are handled by Multiline mode
+func (f *Formatter) writeMultiline(buf *bytes.Buffer, s string) {
+	if f.Multiline == MultilineKeep || strings.IndexAny(s, "\r\n") < 0 {
+		buf.WriteString(s)
+		return
+	}
+	marker := f.ContinuationMarker
+	if marker == "" {
+		marker = DefaultContinuationMarker
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case f.Multiline == MultilineEscape && c == '\n':
+			buf.WriteString(`\n`)
+		case f.Multiline == MultilineEscape && c == '\r':
+			buf.WriteString(`\r`)
+		case f.Multiline == MultilineIndent && c == '\n':
+			buf.WriteByte('\n')
+			buf.WriteString(marker)
+		case f.Multiline == MultilineIndent && c == '\r':
+			// drop CR of CRLF, a lonely CR is treated as line break
+			if i+1 < len(s) && s[i+1] == '\n' {
+				continue
+			}
+			buf.WriteByte('\n')
+			buf.WriteString(marker)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// hasStack report whether err or any error it wraps carry a stack trace
+func hasStack(err error) bool {
+	for err != nil {
+		if _, ok := err.(stackTracer); ok {
+			return true
+		}
+		switch e := err.(type) {
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
 }
 
-func getPid() (pid uint64, pidErr error) {
-	pb := make([]byte, 64)
-	pb = pb[:runtime.Stack(pb, false)]
-	pb = bytes.TrimPrefix(pb, []byte("goroutine "))
-	pb = pb[:bytes.IndexByte(pb, ' ')]
-	if pid, pidErr = strconv.ParseUint(string(pb), 10, 64); pidErr != nil {
-		return 0, errors.WithStack(pidErr)
+// goroutinePrefix first line of runtime.Stack is like "goroutine 18 [running]:"
+var goroutinePrefix = []byte("goroutine ")
+
+// stackPool buffers of getPid, runtime.Stack makes its buffer escape
+var stackPool = sync.Pool{
+	New: func() interface{} {
+		return new([64]byte)
+	},
+}
+
+// getPid Get id of the current goroutine without allocation
+func getPid() (uint64, error) {
+	buf := stackPool.Get().(*[64]byte)
+	defer stackPool.Put(buf)
+	pb := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], goroutinePrefix)
+	var pid uint64
+	var n int
+	for ; n < len(pb) && pb[n] >= '0' && pb[n] <= '9'; n++ {
+		pid = pid*10 + uint64(pb[n]-'0')
+	}
+	if n == 0 {
+		return 0, errors.Errorf("unexpected goroutine header %q", pb)
 	}
-	return
+	return pid, nil
 }
@@ -0,0 +1,6 @@
+//go:build !race
+
+package formatter
+
+// raceEnabled the race detector allocates, allocation counts are not checked
+const raceEnabled = false
@@ -0,0 +1,147 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dedup collapse the identical consecutive entries of a log file, like syslog
+// "last message repeated N times"
+type dedup struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	// key of the last entry written, empty means none
+	key   string
+	level logrus.Level
+	// repeats entries suppressed since the last entry written
+	repeats int
+	// expires end of the run of the last entry, a repeat after it is written again
+	expires time.Time
+	timer   *time.Timer
+	stopped bool
+}
+
+// dedupKey the level, message and fields of the entry, entries with the same key are identical
+func dedupKey(entry *logrus.Entry) string {
+	var b strings.Builder
+	b.WriteString(entry.Level.String())
+	b.WriteByte(0)
+	b.WriteString(entry.Message)
+	if len(entry.Data) == 0 {
+		return b.String()
+	}
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		fmt.Fprint(&b, entry.Data[k])
+	}
+	return b.String()
+}
+
+// routeDedup write the entry to s unless it repeats the last one. A different entry or
+// the end of the timeout writes the repeat count first.
+func (h *fileRouter) routeDedup(s *fileSink, entry *logrus.Entry, key string, enc *encoding) {
+	d := s.dedup
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	if d.key == key && now.Before(d.expires) {
+		d.repeats++
+		return
+	}
+	h.flushRepeats(s, now)
+	b, err := enc.encode(s.format)
+	if err != nil {
+		h.formatError(s, err)
+		return
+	}
+	h.write(s, b)
+	if d.stopped {
+		return
+	}
+	d.key, d.level = key, entry.Level
+	d.expires = now.Add(d.timeout)
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.timeout, func() { h.expireDedup(s) })
+	} else {
+		d.timer.Reset(d.timeout)
+	}
+}
+
+// expireDedup end the run of the last entry when the timeout expires
+func (h *fileRouter) expireDedup(s *fileSink) {
+	d := s.dedup
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	// reset by a later entry, fires again
+	if d.stopped || now.Before(d.expires) {
+		return
+	}
+	h.flushRepeats(s, now)
+	d.key = ""
+}
+
+// flushRepeats write the repeat count of the last entry if it's repeated,
+// MUST hold s.dedup.mu
+func (h *fileRouter) flushRepeats(s *fileSink, now time.Time) {
+	d := s.dedup
+	if d.repeats == 0 {
+		return
+	}
+	repeats := d.repeats
+	d.repeats = 0
+	b, err := s.formatter.Format(&logrus.Entry{
+		Logger:  logrus.StandardLogger(),
+		Time:    now,
+		Level:   d.level,
+		Message: fmt.Sprintf("[GINLOG]Last message repeated %d times.", repeats),
+		Data:    logrus.Fields{},
+	})
+	if err != nil {
+		h.formatError(s, err)
+		return
+	}
+	h.write(s, b)
+}
+
+// stopDedup write the pending repeat count of s, the file is not written by dedup after it
+func (h *fileRouter) stopDedup(s *fileSink) {
+	d := s.dedup
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return
+	}
+	h.flushRepeats(s, time.Now())
+	d.stopped = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
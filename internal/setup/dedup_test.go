@@ -0,0 +1,83 @@
+package setup
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// messages of the log file, without time and caller
+func messages(t *testing.T, path string) []string {
+	b, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	var lines []string
+	re := regexp.MustCompile(`\[[A-Z]+\](.*)$`)
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		if m := re.FindStringSubmatch(line); m != nil {
+			lines = append(lines, m[1])
+		}
+	}
+	return lines
+}
+
+func TestLogger_Dedup(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: dir, LogFilePrefix: "d", DedupTimeout: time.Hour})
+	assert.Nil(t, err)
+	for i := 0; i < 5; i++ {
+		l.Error("disk slow")
+	}
+	l.Info("disk slow")
+	l.Info("disk slow")
+	// fields are compared too
+	l.WithField("id", 1).Info("request")
+	l.WithField("id", 1).Info("request")
+	l.WithField("id", 2).Info("request")
+	l.Info("done")
+	l.Info("done")
+	// the pending repeat count is written by Close
+	assert.Nil(t, l.Close())
+
+	assert.Equal(t, []string{
+		"disk slow",
+		"[GINLOG]Last message repeated 4 times.",
+		"disk slow",
+		"[GINLOG]Last message repeated 1 times.",
+		"request",
+		"[GINLOG]Last message repeated 1 times.",
+		"request",
+		"done",
+		"[GINLOG]Last message repeated 1 times.",
+	}, messages(t, dir+"/latest-combine-d-log"))
+	// every log file collapses its own entries
+	assert.Equal(t, []string{
+		"disk slow",
+		"[GINLOG]Last message repeated 4 times.",
+	}, messages(t, dir+"/latest-error-d-log"))
+}
+
+func TestLogger_DedupTimeout(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: dir, LogFilePrefix: "d",
+		DedupTimeout: 50 * time.Millisecond})
+	assert.Nil(t, err)
+	defer l.Close()
+	for i := 0; i < 3; i++ {
+		l.Warn("retry")
+	}
+	assert.Eventually(t, func() bool {
+		b, _ := os.ReadFile(dir + "/latest-combine-d-log")
+		return strings.Contains(string(b), "Last message repeated 2 times.")
+	}, time.Second, 10*time.Millisecond)
+	// a new run after the timeout
+	l.Warn("retry")
+	assert.Equal(t, []string{
+		"retry",
+		"[GINLOG]Last message repeated 2 times.",
+		"retry",
+	}, messages(t, dir+"/latest-combine-d-log"))
+}
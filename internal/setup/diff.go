@@ -0,0 +1,140 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Options Get a copy of the options the logger is running with,
+// including the levels changed by SetLevel and SetNamedLevels
+func (l *Logger) Options() Options {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	opt := *l.options
+	opt.ExtLoggerWriter = append([]io.Writer(nil), opt.ExtLoggerWriter...)
+	l.named.mu.Lock()
+	opt.Level = l.Logger.GetLevel()
+	opt.NamedLevels = copyLevels(l.named.levels)
+	l.named.mu.Unlock()
+	return opt
+}
+
+// Diff describe the changes from opt to other, like "Level: info -> debug".
+// It's empty if nothing changed.
+func (opt *Options) Diff(other *Options) []string {
+	var changes []string
+	add := func(name string, from, to interface{}) {
+		a, b := fmt.Sprint(from), fmt.Sprint(to)
+		if a != b {
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", name, a, b))
+		}
+	}
+	add("BaseDir", opt.BaseDir, other.BaseDir)
+	add("Level", opt.Level, other.Level)
+	add("ReportCaller", opt.ReportCaller, other.ReportCaller)
+	add("LogFilePrefix", opt.LogFilePrefix, other.LogFilePrefix)
+	add("RotateDuration", opt.RotateDuration, other.RotateDuration)
+	add("ExtLoggerWriter", writerNames(opt.ExtLoggerWriter), writerNames(other.ExtLoggerWriter))
+	add("CustomTimeLayout", opt.CustomTimeLayout, other.CustomTimeLayout)
+	add("Multiline", opt.Multiline, other.Multiline)
+	add("ContinuationMarker", opt.ContinuationMarker, other.ContinuationMarker)
+	add("NamedLevels", levelsText(opt.NamedLevels), levelsText(other.NamedLevels))
+	add("Formatter", opt.Formatter, other.Formatter)
+	add("ExtFormatter", opt.ExtFormatter, other.ExtFormatter)
+	add("Routes", routesText(opt.Routes), routesText(other.Routes))
+	add("PurgeOrphans", opt.PurgeOrphans, other.PurgeOrphans)
+	add("DirQuota", opt.DirQuota, other.DirQuota)
+	add("FallbackWriter", writerNames([]io.Writer{opt.FallbackWriter}), writerNames([]io.Writer{other.FallbackWriter}))
+	add("DirMode", opt.DirMode, other.DirMode)
+	add("FileMode", opt.FileMode, other.FileMode)
+	add("Owner", opt.Owner, other.Owner)
+	add("Group", opt.Group, other.Group)
+	add("FilePattern", opt.FilePattern, other.FilePattern)
+	add("LinkPattern", opt.LinkPattern, other.LinkPattern)
+	add("ExternalRotate", opt.ExternalRotate, other.ExternalRotate)
+	add("Sampling", samplingText(opt.Sampling), samplingText(other.Sampling))
+	add("DedupTimeout", opt.DedupTimeout, other.DedupTimeout)
+	add("Syslog", sinkText(opt.Syslog), sinkText(other.Syslog))
+	add("Journald", sinkText(opt.Journald), sinkText(other.Journald))
+	return changes
+}
+
+func writerNames(writers []io.Writer) string {
+	names := make([]string, 0, len(writers))
+	for _, w := range writers {
+		if f, ok := w.(*os.File); ok {
+			names = append(names, f.Name())
+		} else {
+			names = append(names, fmt.Sprintf("%T", w))
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func levelsText(levels map[string]logrus.Level) string {
+	items := make([]string, 0, len(levels))
+	for k, v := range levels {
+		items = append(items, k+"="+v.String())
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func routesText(routes []Route) string {
+	items := make([]string, 0, len(routes))
+	for _, r := range routes {
+		item := fmt.Sprintf("%s%v%s/%s/%d/%d", r.Name, r.Levels, r.Field, r.MaxAge, r.MaxFiles, r.MaxSize)
+		if r.FilePattern != "" || r.LinkPattern != "" || r.Formatter != "" {
+			item += "/" + r.FilePattern + "/" + r.LinkPattern + "/" + r.Formatter
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, ",")
+}
+
+func samplingText(s *Sampling) string {
+	if s == nil {
+		return ""
+	}
+	items := make([]string, 0, len(s.Levels))
+	for level, rate := range s.Levels {
+		items = append(items, fmt.Sprintf("%s=%d/%d", level, rate.First, rate.Thereafter))
+	}
+	sort.Strings(items)
+	return s.Tick.String() + ":" + strings.Join(items, ",")
+}
+
+// sinkText options of a sink, empty if it's nil
+func sinkText(opt interface{}) string {
+	switch opt := opt.(type) {
+	case *SyslogOptions:
+		if opt != nil {
+			return fmt.Sprintf("%+v", *opt)
+		}
+	case *JournaldOptions:
+		if opt != nil {
+			return fmt.Sprintf("%+v", *opt)
+		}
+	}
+	return ""
+}
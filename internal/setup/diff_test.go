@@ -0,0 +1,20 @@
+package setup
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestOptions_Diff(t *testing.T) {
+	a := &Options{BaseDir: "./logs", Level: logrus.InfoLevel, ExtLoggerWriter: []io.Writer{os.Stdout}}
+	b := &Options{BaseDir: "./logs", Level: logrus.DebugLevel, NamedLevels: map[string]logrus.Level{"gorm": logrus.WarnLevel}}
+	assert.Empty(t, a.Diff(a))
+	assert.Equal(t, []string{
+		`Level: "info" -> "debug"`,
+		`ExtLoggerWriter: "/dev/stdout" -> ""`,
+		`NamedLevels: "" -> "gorm=warning"`,
+	}, a.Diff(b))
+}
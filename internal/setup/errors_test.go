@@ -0,0 +1,43 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"testing"
+)
+
+func TestCombineErrors(t *testing.T) {
+	assert.Nil(t, combineErrors())
+	assert.Nil(t, combineErrors(nil, nil))
+
+	errA := errors.New("a")
+	assert.Equal(t, errA, combineErrors(nil, errA))
+
+	err := combineErrors(errA, nil, combineErrors(errors.New("b"), fs.ErrClosed))
+	assert.Equal(t, "a; b; file already closed", err.Error())
+	assert.True(t, errors.Is(err, fs.ErrClosed))
+	assert.True(t, errors.Is(err, errA))
+	assert.False(t, errors.Is(err, fs.ErrNotExist))
+
+	var pathErr *fs.PathError
+	err = combineErrors(errA, &fs.PathError{Op: "close", Path: "app.log", Err: fs.ErrClosed})
+	assert.True(t, errors.As(err, &pathErr))
+	assert.Equal(t, "app.log", pathErr.Path)
+}
@@ -0,0 +1,147 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// minReopenBackoff wait before the first re-open of a failed log file,
+	// it doubles on every failed attempt up to maxReopenBackoff
+	minReopenBackoff = time.Second
+	maxReopenBackoff = time.Minute
+)
+
+// degradation state of a log file which can't be written, entries go to the fallback writer
+type degradation struct {
+	mu sync.Mutex
+	// failures writes failed or skipped since the file is degraded, 0 means healthy
+	failures int
+	since    time.Time
+	retryAt  time.Time
+	backoff  time.Duration
+	// minBackoff, maxBackoff bounds of backoff
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// fallback where the entries go when the log files can't be written
+type fallback struct {
+	mu           sync.Mutex
+	w            io.Writer
+	onWriteError func(err error)
+	// reportMu call onWriteError one at a time
+	reportMu sync.Mutex
+}
+
+func (f *fallback) write(b []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, _ = f.w.Write(b)
+}
+
+// report call onWriteError on its own goroutine, out of the locks of the logger,
+// so it can log through the same logger
+func (f *fallback) report(err error) {
+	if f.onWriteError == nil {
+		return
+	}
+	go func() {
+		f.reportMu.Lock()
+		defer f.reportMu.Unlock()
+		f.onWriteError(err)
+	}()
+}
+
+// write b to the log file of s. If it fails, b goes to the fallback writer and
+// the file is re-opened with exponential backoff, until it's written again.
+func (h *fileRouter) write(s *fileSink, b []byte) {
+	d := &s.degradation
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failures > 0 {
+		now := time.Now()
+		if now.Before(d.retryAt) {
+			d.failures++
+			h.fallback.write(b)
+			return
+		}
+		// re-open, the next write opens the file again
+		_ = s.writer.Close()
+		alert, err := s.formatter.Format(&logrus.Entry{
+			Logger: logrus.StandardLogger(),
+			Time:   now,
+			Level:  logrus.WarnLevel,
+			Message: fmt.Sprintf("[GINLOG]Log file recovered, %d entries went to the fallback writer in the last %s.",
+				d.failures, now.Sub(d.since).Round(time.Millisecond)),
+			Data: logrus.Fields{},
+		})
+		if err == nil {
+			if _, err = s.writer.Write(alert); err != nil {
+				h.fail(s, b, err)
+				return
+			}
+		}
+		d.failures = 0
+		d.backoff = 0
+	}
+	if _, err := s.writer.Write(b); err != nil {
+		h.fail(s, b, err)
+	}
+}
+
+// fail MUST hold s.degradation.mu
+func (h *fileRouter) fail(s *fileSink, b []byte, err error) {
+	d := &s.degradation
+	if d.failures == 0 {
+		d.since = time.Now()
+	}
+	d.failures++
+	if d.backoff == 0 {
+		d.backoff = d.minBackoff
+	} else if d.backoff *= 2; d.backoff > d.maxBackoff {
+		d.backoff = d.maxBackoff
+	}
+	d.retryAt = time.Now().Add(d.backoff)
+	h.fallback.write(b)
+	h.fallback.report(errors.WithMessagef(err, "[GINLOG]Write %s log error.", s.name))
+}
+
+// Degraded Get names of the log files which can't be written now,
+// their entries go to Options.FallbackWriter
+func (l *Logger) Degraded() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.out.files == nil {
+		return nil
+	}
+	var names []string
+	for _, s := range l.out.files.sinks {
+		s.degradation.mu.Lock()
+		if s.degradation.failures > 0 {
+			names = append(names, s.name)
+		}
+		s.degradation.mu.Unlock()
+	}
+	return names
+}
@@ -0,0 +1,111 @@
+package setup
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lockedBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (lb *lockedBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.b.Write(p)
+}
+
+func (lb *lockedBuffer) String() string {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.b.String()
+}
+
+func TestLogger_Fallback(t *testing.T) {
+	dir := t.TempDir()
+	var fallback lockedBuffer
+	writeErrors := make(chan error, 10)
+	l, err := New(&Options{
+		Level:          logrus.InfoLevel,
+		BaseDir:        dir,
+		Routes:         []Route{{Name: "combine"}},
+		FallbackWriter: &fallback,
+		OnWriteError: func(err error) {
+			writeErrors <- err
+		},
+	})
+	assert.Nil(t, err)
+	s := l.out.files.sinks[0]
+	s.degradation.minBackoff = 50 * time.Millisecond
+	s.degradation.maxBackoff = 50 * time.Millisecond
+
+	l.Info("written")
+	// the file handle breaks, like the disk is full
+	assert.Nil(t, s.writer.fh.Close())
+	l.Info("lost 1")
+	l.Info("lost 2")
+	assert.Contains(t, fallback.String(), "lost 1")
+	assert.Contains(t, fallback.String(), "lost 2")
+	select {
+	case err := <-writeErrors:
+		assert.Contains(t, err.Error(), "Write combine log error")
+	case <-time.After(time.Second):
+		t.Fatal("OnWriteError is not called")
+	}
+	assert.Equal(t, []string{"combine"}, l.Degraded())
+
+	// re-opened after backoff
+	time.Sleep(60 * time.Millisecond)
+	l.Info("back")
+	assert.Empty(t, l.Degraded())
+	assert.NotContains(t, fallback.String(), "back")
+	b, err := os.ReadFile(dir + "/latest-combine-log")
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], "written")
+	assert.Contains(t, lines[1], "[WARNING]")
+	assert.Contains(t, lines[1], "Log file recovered, 2 entries went to the fallback writer")
+	assert.Contains(t, lines[2], "back")
+	assert.Nil(t, l.Close())
+	assert.Len(t, writeErrors, 0)
+}
+
+func TestLogger_FallbackLogError(t *testing.T) {
+	var fallback lockedBuffer
+	var l *Logger
+	l, err := New(&Options{
+		Level:          logrus.InfoLevel,
+		BaseDir:        t.TempDir(),
+		Routes:         []Route{{Name: "combine"}},
+		FallbackWriter: &fallback,
+		// logging through the same logger
+		OnWriteError: func(err error) {
+			l.Warnf("logging degraded: %v", err)
+		},
+	})
+	assert.Nil(t, err)
+	defer l.Close()
+	l.Info("a")
+	assert.Nil(t, l.out.files.sinks[0].writer.fh.Close())
+	done := make(chan struct{})
+	go func() {
+		l.Info("b")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("logging in OnWriteError deadlocks")
+	}
+	assert.Eventually(t, func() bool {
+		return strings.Contains(fallback.String(), "logging degraded: [GINLOG]Write combine log error.")
+	}, time.Second, 10*time.Millisecond)
+}
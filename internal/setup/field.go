@@ -0,0 +1,131 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"context"
+	"github.com/gin-melodic/glog/internal/formatter"
+	"github.com/sirupsen/logrus"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// callerKey entry.Data key of Record.Caller, callerHook moves it to entry.Caller
+// since logrus reports the caller of LogRecord
+const callerKey = "glog.caller"
+
+// Record an entry given by other logging APIs, like the typed fields API and log/slog
+type Record struct {
+	// Time of the entry, zero means now
+	Time    time.Time
+	Level   logrus.Level
+	Message string
+	// Fields typed fields, kept in entry.Data[formatter.FieldsKey] as one value and
+	// encoded one by one by the formatter, without a map entry per field
+	Fields []formatter.Field
+	// Caller reported instead of the caller of LogRecord when ReportCaller is on
+	Caller  *runtime.Frame
+	Context context.Context
+}
+
+// recordEntry the entry of LogRecord and its Data, reused since logrus logs a copy of it
+type recordEntry struct {
+	entry logrus.Entry
+	data  logrus.Fields
+}
+
+var recordPool = sync.Pool{
+	New: func() interface{} {
+		return &recordEntry{data: make(logrus.Fields, 2)}
+	},
+}
+
+func (re *recordEntry) release() {
+	delete(re.data, formatter.FieldsKey)
+	delete(re.data, callerKey)
+	re.entry = logrus.Entry{}
+	recordPool.Put(re)
+}
+
+// LogRecord log r if its level is enabled, Fatal and Panic entries don't exit or panic
+func (l *Logger) LogRecord(r *Record) {
+	if !l.IsLevelEnabled(r.Level) {
+		return
+	}
+	re := recordPool.Get().(*recordEntry)
+	defer re.release()
+	if len(r.Fields) > 0 {
+		re.data[formatter.FieldsKey] = formatter.Fields(r.Fields)
+	}
+	if r.Caller != nil && l.ReportCaller {
+		re.data[callerKey] = r.Caller
+	}
+	re.entry = logrus.Entry{Logger: l.Logger, Data: re.data, Time: r.Time, Context: r.Context}
+	if r.Level == logrus.PanicLevel {
+		// logrus panics with the entry after it's written
+		defer func() {
+			if p := recover(); p != nil {
+				if _, ok := p.(*logrus.Entry); !ok {
+					panic(p)
+				}
+			}
+		}()
+	}
+	re.entry.Log(r.Level, r.Message)
+}
+
+// LogFields log msg with typed fields at level, see Record.Fields.
+// depth is the number of frames between the caller to report and LogFields.
+func (l *Logger) LogFields(depth int, level logrus.Level, msg string, fields []formatter.Field) {
+	if !l.IsLevelEnabled(level) {
+		return
+	}
+	r := Record{Level: level, Message: msg, Fields: fields}
+	if l.ReportCaller {
+		r.Caller, _ = callerFrame(depth + 2)
+	}
+	l.LogRecord(&r)
+}
+
+// callerFrame the frame of the caller skip frames above callerFrame
+func callerFrame(skip int) (*runtime.Frame, bool) {
+	pc, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return nil, false
+	}
+	frame := &runtime.Frame{PC: pc, File: file, Line: line}
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		frame.Function = fn.Name()
+	}
+	return frame, true
+}
+
+// callerHook the first hook of the logger, it reports Record.Caller
+type callerHook struct{}
+
+func (callerHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (callerHook) Fire(entry *logrus.Entry) error {
+	if frame, ok := entry.Data[callerKey].(*runtime.Frame); ok {
+		delete(entry.Data, callerKey)
+		entry.Caller = frame
+	}
+	return nil
+}
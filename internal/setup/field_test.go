@@ -0,0 +1,109 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"bytes"
+	"github.com/gin-melodic/glog/internal/formatter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestLogger_LogFields(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New(&Options{
+		Level:           logrus.InfoLevel,
+		ReportCaller:    true,
+		BaseDir:         t.TempDir(),
+		ExtLoggerWriter: []io.Writer{&out},
+	})
+	assert.Nil(t, err)
+	defer l.Close()
+
+	fields := []formatter.Field{{Key: "user", Type: formatter.StringType, String: "alice"}}
+	l.LogFields(0, logrus.DebugLevel, "hidden", fields)
+	assert.Empty(t, out.String())
+	_, _, line, _ := runtime.Caller(0)
+	l.LogFields(0, logrus.InfoLevel, "paid", fields)
+	assert.Contains(t, out.String(), "[field_test.go:"+strconv.Itoa(line+1)+"][INFO]paid user=alice\n")
+
+	// the caller key doesn't leak to other formatters
+	out.Reset()
+	l.out.formatters[l.out.extFormat] = &logrus.JSONFormatter{}
+	l.LogFields(0, logrus.InfoLevel, "json", fields)
+	assert.Contains(t, out.String(), `"fields":{"user":"alice"}`)
+	assert.Contains(t, out.String(), `"file":"`)
+	assert.NotContains(t, out.String(), callerKey)
+}
+
+func TestLogger_LogRecordPanic(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: t.TempDir(), ExtLoggerWriter: []io.Writer{&out}})
+	assert.Nil(t, err)
+	defer l.Close()
+	assert.NotPanics(t, func() {
+		l.LogRecord(&Record{Level: logrus.PanicLevel, Message: "no panic"})
+		l.LogRecord(&Record{Level: logrus.FatalLevel, Message: "no exit"})
+	})
+	assert.Contains(t, out.String(), "[PANIC]no panic")
+	assert.Contains(t, out.String(), "[FATAL]no exit")
+	// the logger still panics for its own API
+	assert.Panics(t, func() {
+		l.Panic("panic")
+	})
+}
+
+func TestLogger_LogFieldsAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not counted with the race detector")
+	}
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: t.TempDir()})
+	assert.Nil(t, err)
+	defer l.Close()
+	fields := []formatter.Field{
+		{Key: "user", Type: formatter.StringType, String: "alice"},
+		{Key: "n", Type: formatter.IntType, Integer: 3},
+	}
+	typed := testing.AllocsPerRun(100, func() {
+		l.LogFields(0, logrus.InfoLevel, "entry", fields)
+	})
+	mapped := testing.AllocsPerRun(100, func() {
+		l.WithFields(logrus.Fields{"user": "alice", "n": 3}).Info("entry")
+	})
+	// the entry and its Data are reused
+	assert.True(t, typed <= mapped-2, "LogFields %v allocs, WithFields %v allocs", typed, mapped)
+}
+
+func BenchmarkLogger_LogFields(b *testing.B) {
+	fields := []formatter.Field{
+		{Key: "user", Type: formatter.StringType, String: "alice"},
+		{Key: "n", Type: formatter.IntType, Integer: 3},
+	}
+	benchmarkLogger(b, &Options{}, func(l *Logger) {
+		l.LogFields(0, logrus.InfoLevel, "benchmark entry", fields)
+	})
+}
+
+func BenchmarkLogger_WithFields(b *testing.B) {
+	benchmarkLogger(b, &Options{}, func(l *Logger) {
+		l.WithFields(logrus.Fields{"user": "alice", "n": 3}).Info("benchmark entry")
+	})
+}
@@ -0,0 +1,244 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cleanupInterval the janitor also cleans up periodically, besides on rotation
+const cleanupInterval = time.Hour
+
+// quotaInterval how often the janitor checks Options.DirQuota if it's set
+const quotaInterval = time.Minute
+
+// logFile a log file found by the janitor
+type logFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// janitor remove log files out of retention and over quota in background
+type janitor struct {
+	baseDir      string
+	purgeOrphans bool
+	quota        int64
+	sinks        []*fileSink
+	// warnf report purging ahead of the retention
+	warnf func(format string, args ...interface{})
+	kick  chan struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newJanitor(opt *Options, sinks []*fileSink) *janitor {
+	j := &janitor{
+		baseDir:      opt.BaseDir,
+		purgeOrphans: opt.PurgeOrphans,
+		quota:        opt.DirQuota,
+		sinks:        sinks,
+		kick:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+	if opt.ExternalRotate {
+		// only the fixed names are globbed, they are the files being written
+		j.quota = 0
+	}
+	for _, s := range sinks {
+		s.writer.rotated = j.Kick
+	}
+	return j
+}
+
+// start the janitor, warnings are logged by warnf
+func (j *janitor) start(warnf func(format string, args ...interface{})) {
+	j.warnf = warnf
+	j.wg.Add(1)
+	go j.run()
+	j.Kick()
+}
+
+func (j *janitor) run() {
+	defer j.wg.Done()
+	interval := cleanupInterval
+	if j.quota > 0 {
+		interval = quotaInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.kick:
+		case <-ticker.C:
+		case <-j.done:
+			return
+		}
+		j.clean(time.Now())
+	}
+}
+
+// Kick ask the janitor to clean up soon, it never blocks
+func (j *janitor) Kick() {
+	select {
+	case j.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (j *janitor) stop() {
+	close(j.done)
+	j.wg.Wait()
+}
+
+func (j *janitor) clean(now time.Time) {
+	for _, s := range j.sinks {
+		s.clean(now)
+		if j.purgeOrphans {
+			j.cleanOrphans(s, now)
+		}
+	}
+	if j.quota > 0 {
+		j.enforceQuota()
+	}
+}
+
+// enforceQuota remove the oldest log files of all sinks until their total size is under
+// the quota, the files being written are never removed
+func (j *janitor) enforceQuota() {
+	var files []logFile
+	var total int64
+	active := map[string]bool{}
+	for _, s := range j.sinks {
+		for _, f := range globFiles(s.writer.glob) {
+			files = append(files, f)
+			total += f.size
+		}
+		active[s.writer.current()] = true
+	}
+	if total <= j.quota {
+		return
+	}
+	// oldest first
+	sort.Slice(files, func(i, k int) bool {
+		return files[i].modTime.Before(files[k].modTime)
+	})
+	var removed int
+	var freed int64
+	for _, f := range files {
+		if total <= j.quota {
+			break
+		}
+		if active[f.path] {
+			continue
+		}
+		if err := os.Remove(f.path); err == nil {
+			removed++
+			freed += f.size
+			total -= f.size
+		}
+	}
+	if removed > 0 {
+		j.warnf("[GINLOG]Log files in %s exceed the quota of %d bytes, removed %d oldest files (%d bytes) ahead of retention.",
+			j.baseDir, j.quota, removed, freed)
+	}
+	if total > j.quota {
+		j.warnf("[GINLOG]Log files in %s still take %d bytes over the quota of %d bytes, only the files being written are left.",
+			j.baseDir, total-j.quota, j.quota)
+	}
+}
+
+// clean remove the files of the sink out of its retention, never the file being written
+func (s *fileSink) clean(now time.Time) {
+	files := globFiles(s.writer.glob)
+	// after globbing, a file opened meanwhile is not in files
+	current := s.writer.current()
+	// newest first
+	sort.Slice(files, func(i, k int) bool {
+		return files[i].modTime.After(files[k].modTime)
+	})
+	var count int
+	var total int64
+	for _, f := range files {
+		if f.path == current {
+			count++
+			total += f.size
+		}
+	}
+	for _, f := range files {
+		if f.path == current {
+			continue
+		}
+		count++
+		total += f.size
+		if (s.maxAge > 0 && now.Sub(f.modTime) > s.maxAge) ||
+			(s.maxFiles > 0 && count > s.maxFiles) ||
+			(s.maxSize > 0 && total > s.maxSize) {
+			_ = os.Remove(f.path)
+		}
+	}
+}
+
+// cleanOrphans remove the files of the sink name left by other prefixes or processes,
+// and dangling links, when they are older than the max age of the sink
+func (j *janitor) cleanOrphans(s *fileSink, now time.Time) {
+	own := map[string]bool{}
+	for _, f := range globFiles(s.writer.glob) {
+		own[f.path] = true
+	}
+	var orphans []logFile
+	for _, p := range s.names.orphans {
+		orphans = append(orphans, globFiles(filepath.Join(j.baseDir, p))...)
+	}
+	for _, f := range orphans {
+		if !own[f.path] && s.maxAge > 0 && now.Sub(f.modTime) > s.maxAge {
+			_ = os.Remove(f.path)
+		}
+	}
+	for _, p := range s.names.links {
+		links, _ := filepath.Glob(filepath.Join(j.baseDir, p))
+		for _, l := range links {
+			if fi, err := os.Lstat(l); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+				if _, err := os.Stat(l); os.IsNotExist(err) {
+					_ = os.Remove(l)
+				}
+			}
+		}
+	}
+}
+
+// globFiles the regular files matched by pattern
+func globFiles(pattern string) []logFile {
+	matches, _ := filepath.Glob(pattern)
+	files := make([]logFile, 0, len(matches))
+	for _, m := range matches {
+		if strings.HasSuffix(m, "_symlink") {
+			continue
+		}
+		fi, err := os.Lstat(m)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, logFile{path: m, size: fi.Size(), modTime: fi.ModTime()})
+	}
+	return files
+}
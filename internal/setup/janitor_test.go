@@ -0,0 +1,137 @@
+package setup
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// touch create a log file of size bytes modified age ago
+func touch(t *testing.T, path string, size int, age time.Duration) {
+	assert.Nil(t, os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644))
+	mt := time.Now().Add(-age)
+	assert.Nil(t, os.Chtimes(path, mt, mt))
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func TestFileSink_Clean(t *testing.T) {
+	day := 24 * time.Hour
+	cases := []struct {
+		name string
+		sink *fileSink
+		kept []string
+	}{
+		{"age", &fileSink{maxAge: 2 * day}, []string{"d0", "d1", "d2"}},
+		{"count", &fileSink{maxFiles: 2}, []string{"d0", "d1"}},
+		{"size", &fileSink{maxSize: 250}, []string{"d0", "d1"}},
+		{"all", &fileSink{maxAge: 5 * day, maxFiles: 4, maxSize: 1000}, []string{"d0", "d1", "d2", "d3"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := newRotateFile(dir+"/app-%Y%m%d.log", "", testPerm())
+			assert.Nil(t, err)
+			// d0 is the file being written
+			_, err = w.Write([]byte(strings.Repeat("x", 99) + "\n"))
+			assert.Nil(t, err)
+			assert.Nil(t, os.Rename(w.current(), dir+"/app-d0.log"))
+			w.name = filepath.Join(dir, "app-d0.log")
+			for i := 1; i < 6; i++ {
+				touch(t, filepath.Join(dir, "app-d"+string(rune('0'+i))+".log"), 100, time.Duration(i)*day-time.Hour)
+			}
+			c.sink.writer = w
+			c.sink.clean(time.Now())
+			var kept []string
+			for _, f := range globFiles(w.glob) {
+				kept = append(kept, strings.TrimSuffix(strings.TrimPrefix(filepath.Base(f.path), "app-"), ".log"))
+			}
+			assert.Equal(t, c.kept, kept)
+			assert.Nil(t, w.Close())
+		})
+	}
+
+	// the file being written is kept even it's out of retention
+	dir := t.TempDir()
+	w, err := newRotateFile(dir+"/app-%Y%m%d.log", "", testPerm())
+	assert.Nil(t, err)
+	_, err = w.Write([]byte("x\n"))
+	assert.Nil(t, err)
+	s := &fileSink{writer: w, maxAge: time.Hour, maxFiles: 1, maxSize: 1}
+	touch(t, w.current(), 100, 2*time.Hour)
+	s.clean(time.Now())
+	assert.True(t, exists(w.current()))
+	assert.Nil(t, w.Close())
+}
+
+func TestJanitor_Orphans(t *testing.T) {
+	dir := t.TempDir()
+	day := 24 * time.Hour
+	touch(t, dir+"/old-combine-20200101.log", 10, 30*day)
+	touch(t, dir+"/combine-20200101.log", 10, 30*day)
+	touch(t, dir+"/recent-combine-20200101.log", 10, day)
+	touch(t, dir+"/old-error-20200101.log", 10, 30*day)
+	assert.Nil(t, os.Symlink("old-combine-20200101.log", dir+"/latest-combine-old-log"))
+
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: dir, LogFilePrefix: "new", PurgeOrphans: true,
+		Routes: []Route{{Name: "combine", MaxAge: 7 * day}}})
+	assert.Nil(t, err)
+	l.Info("new entry")
+	assert.Eventually(t, func() bool {
+		return !exists(dir+"/old-combine-20200101.log") && !exists(dir+"/latest-combine-old-log")
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, exists(dir+"/combine-20200101.log"))
+	assert.True(t, exists(dir+"/recent-combine-20200101.log"))
+	// only the files of the routes are cleaned
+	assert.True(t, exists(dir+"/old-error-20200101.log"))
+	assert.True(t, exists(dir+"/latest-combine-new-log"))
+	assert.Nil(t, l.Close())
+}
+
+func TestJanitor_Quota(t *testing.T) {
+	dir := t.TempDir()
+	day := 24 * time.Hour
+	touch(t, dir+"/q-combine-20200101.log", 400, 3*day)
+	touch(t, dir+"/q-combine-20200102.log", 400, 2*day)
+	touch(t, dir+"/q-error-20200102.log", 400, 2*day-time.Hour)
+	touch(t, dir+"/q-combine-20200103.log", 400, day)
+	// not a file of the logger
+	touch(t, dir+"/other-combine-20200101.log", 400, 3*day)
+
+	// room for the new entries, which may be counted or not
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: dir, LogFilePrefix: "q", DirQuota: 1150})
+	assert.Nil(t, err)
+	l.Info("new entry")
+	assert.Eventually(t, func() bool {
+		b, _ := os.ReadFile(dir + "/latest-combine-q-log")
+		return strings.Contains(string(b), "removed 2 oldest files (800 bytes) ahead of retention")
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, exists(dir+"/q-combine-20200101.log"))
+	assert.False(t, exists(dir+"/q-combine-20200102.log"))
+	assert.True(t, exists(dir+"/q-error-20200102.log"))
+	assert.True(t, exists(dir+"/q-combine-20200103.log"))
+	assert.True(t, exists(dir+"/other-combine-20200101.log"))
+	assert.Nil(t, l.Close())
+}
+
+func TestJanitor_ExternalRotate(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, dir+"/app-combine.log", 400, 10*24*time.Hour)
+	touch(t, dir+"/app-error.log", 400, 10*24*time.Hour)
+
+	// the files of logrotate are never removed, even before they are written
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: dir, LogFilePrefix: "app", ExternalRotate: true,
+		PurgeOrphans: true, DirQuota: 100, Routes: []Route{{Name: "combine", MaxFiles: 1}, {Name: "error"}}})
+	assert.Nil(t, err)
+	l.out.files.janitor.clean(time.Now())
+	assert.True(t, exists(dir+"/app-combine.log"))
+	assert.True(t, exists(dir+"/app-error.log"))
+	assert.Nil(t, l.Close())
+}
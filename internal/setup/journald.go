@@ -0,0 +1,190 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+// DefaultJournaldSocket the socket of the native protocol of systemd-journald
+const DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldOptions send the entries to systemd-journald by its native protocol.
+// Fields of the entries become journal fields, like "user_id" as USER_ID.
+type JournaldOptions struct {
+	// Socket default DefaultJournaldSocket
+	Socket string
+	// Identifier SYSLOG_IDENTIFIER of the entries, default the name of the executable
+	Identifier string
+}
+
+// journaldSink write the entries to journald, one datagram per entry
+type journaldSink struct {
+	conn       *netSink
+	identifier string
+}
+
+func newJournaldSink(opt *JournaldOptions, fb *fallback) (*journaldSink, error) {
+	socket := opt.Socket
+	if socket == "" {
+		socket = DefaultJournaldSocket
+	}
+	identifier := opt.Identifier
+	if identifier == "" {
+		identifier = appName()
+	}
+	conn, err := dialSink("journald", "unixgram", socket, false, fb, journalText)
+	if err != nil {
+		return nil, err
+	}
+	return &journaldSink{conn: conn, identifier: identifier}, nil
+}
+
+// journalFields fields with a meaning to journald (systemd.journal-fields(7)), most of them set
+// by the sink, a user field of the same name would override or forge them
+var journalFields = map[string]bool{
+	"MESSAGE":            true,
+	"MESSAGE_ID":         true,
+	"PRIORITY":           true,
+	"CODE_FILE":          true,
+	"CODE_LINE":          true,
+	"CODE_FUNC":          true,
+	"ERRNO":              true,
+	"INVOCATION_ID":      true,
+	"USER_INVOCATION_ID": true,
+	"SYSLOG_FACILITY":    true,
+	"SYSLOG_IDENTIFIER":  true,
+	"SYSLOG_PID":         true,
+	"SYSLOG_TIMESTAMP":   true,
+	"SYSLOG_RAW":         true,
+	"DOCUMENTATION":      true,
+	"TID":                true,
+	"UNIT":               true,
+	"USER_UNIT":          true,
+}
+
+// journalKey the journal field name of key: upper case letters, digits and '_', not starting
+// with '_' (reserved for trusted fields) or a digit, at most 64 characters. Keys which would
+// start with a digit or collide with journalFields get the prefix F_.
+func journalKey(key string) string {
+	b := make([]byte, 0, len(key))
+	for i := 0; i < len(key) && len(b) < 64; i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			b = append(b, c-'a'+'A')
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			b = append(b, c)
+		case len(b) > 0:
+			b = append(b, '_')
+		}
+	}
+	if len(b) == 0 || (b[0] >= '0' && b[0] <= '9') || journalFields[string(b)] {
+		if len(b) > 62 {
+			b = b[:62]
+		}
+		return "F_" + string(b)
+	}
+	return string(b)
+}
+
+// appendJournalField write the field in the native protocol, values with line breaks are
+// written as the name, a line break, 64-bit little endian length and the raw value
+func appendJournalField(b []byte, key, value string) []byte {
+	b = append(b, key...)
+	if strings.IndexByte(value, '\n') < 0 {
+		b = append(b, '=')
+		b = append(b, value...)
+		return append(b, '\n')
+	}
+	b = append(b, '\n')
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(value)))
+	b = append(b, value...)
+	return append(b, '\n')
+}
+
+// journalText the fields of a message of the native protocol on one line, like
+// MESSAGE="query failed\nSELECT 1" PRIORITY=3
+func journalText(b []byte) []byte {
+	var line []byte
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, "=\n")
+		if i < 0 {
+			break
+		}
+		key, value := b[:i], []byte(nil)
+		if b[i] == '=' {
+			if end := bytes.IndexByte(b, '\n'); end < 0 {
+				value, b = b[i+1:], nil
+			} else {
+				value, b = b[i+1:end], b[end+1:]
+			}
+		} else {
+			if len(b) < i+9 {
+				break
+			}
+			n := int(binary.LittleEndian.Uint64(b[i+1 : i+9]))
+			if n > len(b)-i-9 {
+				break
+			}
+			value, b = b[i+9:i+9+n], b[i+9+n:]
+			if len(b) > 0 {
+				// the line break after the value
+				b = b[1:]
+			}
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, key...)
+		line = append(line, '=')
+		if bytes.ContainsAny(value, " \"\n") || len(value) == 0 {
+			line = strconv.AppendQuote(line, string(value))
+		} else {
+			line = append(line, value...)
+		}
+	}
+	return append(line, '\n')
+}
+
+func (s *journaldSink) format(entry *logrus.Entry) []byte {
+	b := make([]byte, 0, 256+len(entry.Message))
+	b = appendJournalField(b, "MESSAGE", entry.Message)
+	b = appendJournalField(b, "PRIORITY", strconv.Itoa(syslogSeverity(entry.Level)))
+	b = appendJournalField(b, "SYSLOG_IDENTIFIER", s.identifier)
+	if entry.Caller != nil {
+		b = appendJournalField(b, "CODE_FILE", entry.Caller.File)
+		b = appendJournalField(b, "CODE_LINE", strconv.Itoa(entry.Caller.Line))
+		b = appendJournalField(b, "CODE_FUNC", entry.Caller.Function)
+	}
+	for _, f := range sinkFields(entry) {
+		b = appendJournalField(b, journalKey(f.key), f.value)
+	}
+	return b
+}
+
+func (s *journaldSink) write(entry *logrus.Entry) {
+	s.conn.send(s.format(entry))
+}
+
+func (s *journaldSink) close() error {
+	return s.conn.close()
+}
@@ -0,0 +1,92 @@
+package setup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/gin-melodic/glog/internal/formatter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseJournal read the fields of a datagram of the native protocol
+func parseJournal(t *testing.T, b []byte) map[string]string {
+	fields := map[string]string{}
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, "=\n")
+		if !assert.True(t, i > 0) {
+			return fields
+		}
+		key := string(b[:i])
+		if b[i] == '=' {
+			end := bytes.IndexByte(b, '\n')
+			fields[key] = string(b[i+1 : end])
+			b = b[end+1:]
+			continue
+		}
+		n := binary.LittleEndian.Uint64(b[i+1 : i+9])
+		fields[key] = string(b[i+9 : i+9+int(n)])
+		b = b[i+9+int(n)+1:]
+	}
+	return fields
+}
+
+func TestLogger_Journald(t *testing.T) {
+	// short path, unix socket paths are limited to about 100 bytes
+	dir, err := os.MkdirTemp("", "glog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	socket := dir + "/journal.sock"
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: t.TempDir(), ReportCaller: true,
+		Journald: &JournaldOptions{Socket: socket, Identifier: "api"}})
+	assert.Nil(t, err)
+	defer l.Close()
+	l.LogFields(0, logrus.ErrorLevel, "query failed\nSELECT 1", []formatter.Field{
+		{Key: "user_id", Type: formatter.IntType, Integer: 7},
+		{Key: "db.name", Type: formatter.StringType, String: "orders"},
+		{Key: "message", Type: formatter.StringType, String: "forged"},
+	})
+	buf := make([]byte, 65536)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	assert.Nil(t, err)
+	fields := parseJournal(t, buf[:n])
+	assert.Equal(t, "query failed\nSELECT 1", fields["MESSAGE"])
+	assert.Equal(t, "3", fields["PRIORITY"])
+	assert.Equal(t, "api", fields["SYSLOG_IDENTIFIER"])
+	assert.Equal(t, "7", fields["USER_ID"])
+	assert.Equal(t, "orders", fields["DB_NAME"])
+	assert.Equal(t, "forged", fields["F_MESSAGE"])
+	assert.True(t, strings.HasSuffix(fields["CODE_FILE"], "journald_test.go"), fields["CODE_FILE"])
+	assert.Equal(t, "github.com/gin-melodic/glog/internal/setup.TestLogger_Journald", fields["CODE_FUNC"])
+}
+
+func TestJournalKey(t *testing.T) {
+	assert.Equal(t, "USER_ID", journalKey("user_id"))
+	assert.Equal(t, "HTTP_STATUS", journalKey("http.status"))
+	assert.Equal(t, "HIDDEN", journalKey("_hidden"))
+	assert.Equal(t, "F_2FA", journalKey("2fa"))
+	assert.Equal(t, "F_", journalKey("..."))
+	assert.Len(t, journalKey(strings.Repeat("a", 100)), 64)
+	assert.Len(t, journalKey("1"+strings.Repeat("a", 100)), 64)
+	// reserved names
+	assert.Equal(t, "F_MESSAGE", journalKey("message"))
+	assert.Equal(t, "F_PRIORITY", journalKey("_PRIORITY"))
+	assert.Equal(t, "F_SYSLOG_IDENTIFIER", journalKey("syslog.identifier"))
+	assert.Equal(t, "MESSAGE_TEXT", journalKey("message_text"))
+}
+
+func TestJournalText(t *testing.T) {
+	b := appendJournalField(nil, "MESSAGE", "query failed\nSELECT 1")
+	b = appendJournalField(b, "PRIORITY", "3")
+	b = appendJournalField(b, "USER", "")
+	assert.Equal(t, `MESSAGE="query failed\nSELECT 1" PRIORITY=3 USER=""`+"\n", string(journalText(b)))
+}
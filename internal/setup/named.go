@@ -0,0 +1,133 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"github.com/gin-melodic/glog/internal/formatter"
+	"github.com/sirupsen/logrus"
+	"path"
+	"sync"
+)
+
+// DefaultNamePattern pattern matches every named logger without a more specific pattern
+const DefaultNamePattern = "*"
+
+// namedLoggers registry of the sub loggers created by Logger.Named
+type namedLoggers struct {
+	mu      sync.Mutex
+	loggers map[string]*logrus.Logger
+	// levels pattern -> level, pattern is a name, a path.Match pattern or DefaultNamePattern
+	levels map[string]logrus.Level
+}
+
+// Named Get the sub logger with the name, entries are tagged with the name and
+// filtered by the level matched in Options.NamedLevels
+func (l *Logger) Named(name string) *logrus.Entry {
+	l.named.mu.Lock()
+	defer l.named.mu.Unlock()
+	child, ok := l.named.loggers[name]
+	if !ok {
+		child = &logrus.Logger{
+			Out:          l.Out,
+			Hooks:        l.Hooks,
+			Formatter:    l.Formatter,
+			ReportCaller: l.ReportCaller,
+			Level:        l.levelFor(name),
+			ExitFunc:     l.ExitFunc,
+		}
+		if l.named.loggers == nil {
+			l.named.loggers = map[string]*logrus.Logger{}
+		}
+		l.named.loggers[name] = child
+	}
+	return child.WithField(formatter.NameKey, name)
+}
+
+// SetLevel change level of the logger, named loggers without a matched pattern follow it
+func (l *Logger) SetLevel(level logrus.Level) {
+	l.Logger.SetLevel(level)
+	l.named.mu.Lock()
+	defer l.named.mu.Unlock()
+	l.refreshNamedLevels()
+}
+
+// SetNamedLevels replace the per name levels, see Options.NamedLevels
+func (l *Logger) SetNamedLevels(levels map[string]logrus.Level) {
+	l.named.mu.Lock()
+	defer l.named.mu.Unlock()
+	l.named.levels = copyLevels(levels)
+	l.refreshNamedLevels()
+}
+
+// SetLevels change the level and the per name levels together, the outputs are kept
+func (l *Logger) SetLevels(level logrus.Level, levels map[string]logrus.Level) {
+	l.named.mu.Lock()
+	defer l.named.mu.Unlock()
+	l.Logger.SetLevel(level)
+	l.named.levels = copyLevels(levels)
+	l.refreshNamedLevels()
+}
+
+// NamedLevel Get the level of the named logger
+func (l *Logger) NamedLevel(name string) logrus.Level {
+	l.named.mu.Lock()
+	defer l.named.mu.Unlock()
+	return l.levelFor(name)
+}
+
+// refreshNamedLevels MUST hold named.mu
+func (l *Logger) refreshNamedLevels() {
+	for name, child := range l.named.loggers {
+		child.SetLevel(l.levelFor(name))
+	}
+}
+
+// levelFor resolve level of the name, the exact name wins, then the longest
+// matched pattern, then DefaultNamePattern, otherwise the level of l. MUST hold named.mu
+func (l *Logger) levelFor(name string) logrus.Level {
+	if level, ok := l.named.levels[name]; ok {
+		return level
+	}
+	best := ""
+	for pattern := range l.named.levels {
+		if pattern == DefaultNamePattern || len(pattern) < len(best) ||
+			(len(pattern) == len(best) && pattern > best) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			best = pattern
+		}
+	}
+	if best != "" {
+		return l.named.levels[best]
+	}
+	if level, ok := l.named.levels[DefaultNamePattern]; ok {
+		return level
+	}
+	return l.GetLevel()
+}
+
+func copyLevels(levels map[string]logrus.Level) map[string]logrus.Level {
+	if levels == nil {
+		return nil
+	}
+	c := make(map[string]logrus.Level, len(levels))
+	for k, v := range levels {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,70 @@
+package setup
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestLogger_Named(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New(&Options{
+		Level:           logrus.InfoLevel,
+		BaseDir:         "./test-named-logs",
+		LogFilePrefix:   "test",
+		ExtLoggerWriter: []io.Writer{&out},
+		NamedLevels: map[string]logrus.Level{
+			"payments":   logrus.DebugLevel,
+			"payments.*": logrus.TraceLevel,
+			"gorm":       logrus.WarnLevel,
+		},
+	})
+	assert.Nil(t, err)
+	defer func() {
+		assert.Nil(t, l.Close())
+		assert.Nil(t, os.RemoveAll("./test-named-logs"))
+	}()
+
+	assert.Equal(t, logrus.DebugLevel, l.NamedLevel("payments"))
+	assert.Equal(t, logrus.TraceLevel, l.NamedLevel("payments.db"))
+	assert.Equal(t, logrus.WarnLevel, l.NamedLevel("gorm"))
+	assert.Equal(t, logrus.InfoLevel, l.NamedLevel("gin"))
+
+	l.Debug("base debug")
+	l.Named("payments").Debug("payments debug")
+	l.Named("gorm").Info("gorm info")
+	assert.NotContains(t, out.String(), "base debug")
+	assert.Contains(t, out.String(), "[DEBUG][payments]payments debug")
+	assert.NotContains(t, out.String(), "gorm info")
+
+	// loggers without pattern follow the base level
+	l.SetLevel(logrus.DebugLevel)
+	l.Named("gin").Debug("gin debug")
+	assert.Contains(t, out.String(), "[DEBUG][gin]gin debug")
+	assert.Equal(t, logrus.WarnLevel, l.NamedLevel("gorm"))
+
+	// default pattern
+	l.SetNamedLevels(map[string]logrus.Level{"*": logrus.ErrorLevel, "gorm": logrus.DebugLevel})
+	assert.Equal(t, logrus.ErrorLevel, l.NamedLevel("gin"))
+	assert.Equal(t, logrus.ErrorLevel, l.NamedLevel("payments"))
+	assert.Equal(t, logrus.DebugLevel, l.NamedLevel("gorm"))
+	assert.Equal(t, logrus.DebugLevel, l.GetLevel())
+}
+
+func TestLogger_SetLevels(t *testing.T) {
+	l := NewConsole(io.Discard, logrus.InfoLevel)
+	l.Named("gorm")
+	l.SetLevels(logrus.WarnLevel, map[string]logrus.Level{"gorm": logrus.DebugLevel})
+	assert.Equal(t, logrus.WarnLevel, l.GetLevel())
+	assert.Equal(t, logrus.DebugLevel, l.NamedLevel("gorm"))
+	assert.Equal(t, logrus.WarnLevel, l.NamedLevel("gin"))
+	// Options reports the levels in use
+	opt := l.Options()
+	assert.Equal(t, logrus.WarnLevel, opt.Level)
+	assert.Equal(t, map[string]logrus.Level{"gorm": logrus.DebugLevel}, opt.NamedLevels)
+	l.SetLevel(logrus.ErrorLevel)
+	assert.Equal(t, logrus.ErrorLevel, l.Options().Level)
+}
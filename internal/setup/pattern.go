@@ -0,0 +1,158 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Placeholders of the file name and link patterns, replaced before the strftime verbs
+const (
+	// PrefixPlaceholder Options.LogFilePrefix
+	PrefixPlaceholder = "{prefix}"
+	// NamePlaceholder Route.Name
+	NamePlaceholder = "{name}"
+	// HostPlaceholder host name, so several hosts can share a dir
+	HostPlaceholder = "{host}"
+	// PidPlaceholder process id, so several instances can share a dir
+	PidPlaceholder = "{pid}"
+)
+
+// NoLink set a link pattern to NoLink to disable the symlink
+const NoLink = "-"
+
+// default file name and link patterns, without prefix if Options.LogFilePrefix is empty
+const (
+	defaultFilePattern     = "{prefix}-{name}-%Y%m%d.log"
+	defaultBareFilePattern = "{name}-%Y%m%d.log"
+	defaultLinkPattern     = "latest-{name}-{prefix}-log"
+	defaultBareLinkPattern = "latest-{name}-log"
+	// fixed names of Options.ExternalRotate
+	defaultFixedFilePattern     = "{prefix}-{name}.log"
+	defaultBareFixedFilePattern = "{name}.log"
+)
+
+// sinkNames where a sink writes, patterns are relative to Options.BaseDir
+type sinkNames struct {
+	// file strftime pattern of the log files
+	file string
+	// link name of the symlink, empty means no symlink
+	link string
+	// orphans globs of the log files left by other prefixes or processes
+	orphans []string
+	// links glob of the symlinks left by other prefixes or processes
+	links []string
+}
+
+// newSinkNames resolve the file name and link patterns of route r,
+// a pattern of r takes precedence over the one of opt
+func newSinkNames(opt *Options, r *Route) (*sinkNames, error) {
+	file, link := opt.FilePattern, opt.LinkPattern
+	if r.FilePattern != "" {
+		file = r.FilePattern
+	}
+	if r.LinkPattern != "" {
+		link = r.LinkPattern
+	}
+	var filePatterns, linkPatterns []string
+	if file == "" {
+		// left by either prefix or no prefix
+		filePatterns = []string{defaultFilePattern, defaultBareFilePattern}
+		if opt.ExternalRotate {
+			filePatterns = []string{defaultFixedFilePattern, defaultBareFixedFilePattern}
+		}
+		file = filePatterns[0]
+		if opt.LogFilePrefix == "" {
+			file = filePatterns[1]
+		}
+	} else {
+		filePatterns = []string{file}
+	}
+	if opt.ExternalRotate {
+		if strings.Contains(strings.ReplaceAll(file, "%%", ""), "%") {
+			return nil, errors.Errorf("file pattern %q of route %s must be a fixed name to be rotated externally", file, r.Name)
+		}
+		// the file name never changes, the link is useless
+		if link == "" {
+			link = NoLink
+		}
+	}
+	if link == "" {
+		linkPatterns = []string{defaultLinkPattern, defaultBareLinkPattern}
+		if opt.LogFilePrefix == "" {
+			link = defaultBareLinkPattern
+		} else {
+			link = defaultLinkPattern
+		}
+	} else if link != NoLink {
+		linkPatterns = []string{link}
+	}
+
+	host, _ := os.Hostname()
+	exact := strings.NewReplacer(
+		PrefixPlaceholder, opt.LogFilePrefix,
+		NamePlaceholder, r.Name,
+		HostPlaceholder, escapeName(host),
+		PidPlaceholder, strconv.Itoa(os.Getpid()),
+	)
+	// other prefixes and processes of this host
+	wildcard := strings.NewReplacer(
+		PrefixPlaceholder, "*",
+		NamePlaceholder, r.Name,
+		HostPlaceholder, escapeName(host),
+		PidPlaceholder, "*",
+	)
+	n := &sinkNames{file: exact.Replace(file)}
+	if err := checkLocal(n.file); err != nil {
+		return nil, errors.WithMessagef(err, "file pattern %q of route %s", file, r.Name)
+	}
+	if link != NoLink {
+		n.link = exact.Replace(link)
+		if err := checkLocal(n.link); err != nil {
+			return nil, errors.WithMessagef(err, "link pattern %q of route %s", link, r.Name)
+		}
+		if strings.Contains(n.link, "%") {
+			return nil, errors.Errorf("link pattern %q of route %s must not contain strftime verbs", link, r.Name)
+		}
+	}
+	for _, p := range filePatterns {
+		n.orphans = append(n.orphans, strftimeVerbs.ReplaceAllString(wildcard.Replace(p), "*"))
+	}
+	for _, p := range linkPatterns {
+		n.links = append(n.links, wildcard.Replace(p))
+	}
+	return n, nil
+}
+
+// checkLocal a pattern must stay in the log dir
+func checkLocal(pattern string) error {
+	clean := filepath.Clean(pattern)
+	if filepath.IsAbs(clean) || clean == "." || clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return errors.New("must be a path inside the log dir")
+	}
+	return nil
+}
+
+// escapeName make a host name safe in a file name and strftime pattern
+func escapeName(name string) string {
+	return strings.NewReplacer("/", "_", `\`, "_", "%", "_").Replace(name)
+}
@@ -0,0 +1,104 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewSinkNames(t *testing.T) {
+	host, _ := os.Hostname()
+	pid := strconv.Itoa(os.Getpid())
+
+	n, err := newSinkNames(&Options{LogFilePrefix: "api"}, &Route{Name: "combine"})
+	assert.Nil(t, err)
+	assert.Equal(t, "api-combine-%Y%m%d.log", n.file)
+	assert.Equal(t, "latest-combine-api-log", n.link)
+	assert.Equal(t, []string{"*-combine-*.log", "combine-*.log"}, n.orphans)
+
+	n, err = newSinkNames(&Options{}, &Route{Name: "combine"})
+	assert.Nil(t, err)
+	assert.Equal(t, "combine-%Y%m%d.log", n.file)
+	assert.Equal(t, "latest-combine-log", n.link)
+
+	opt := &Options{
+		LogFilePrefix: "api",
+		FilePattern:   "{host}/{prefix}.{name}.{pid}.%Y-%m-%d.log",
+		LinkPattern:   "{name}.log",
+	}
+	n, err = newSinkNames(opt, &Route{Name: "error"})
+	assert.Nil(t, err)
+	assert.Equal(t, host+"/api.error."+pid+".%Y-%m-%d.log", n.file)
+	assert.Equal(t, "error.log", n.link)
+	assert.Equal(t, []string{host + "/*.error.*.*-*-*.log"}, n.orphans)
+
+	// the route takes precedence
+	n, err = newSinkNames(opt, &Route{Name: "audit", FilePattern: "audit-%Y.log", LinkPattern: NoLink})
+	assert.Nil(t, err)
+	assert.Equal(t, "audit-%Y.log", n.file)
+	assert.Equal(t, "", n.link)
+	assert.Empty(t, n.links)
+
+	for _, r := range []Route{
+		{Name: "a", FilePattern: "../{name}-%Y.log"},
+		{Name: "a", FilePattern: "/var/log/{name}-%Y.log"},
+		{Name: "a", LinkPattern: "latest-%Y"},
+	} {
+		_, err = newSinkNames(&Options{}, &r)
+		assert.Error(t, err, r.FilePattern+r.LinkPattern)
+	}
+}
+
+func TestNew_FilePattern(t *testing.T) {
+	dir := t.TempDir()
+	pid := strconv.Itoa(os.Getpid())
+	// left by another process long ago
+	touch(t, filepath.Join(dir, "combine.1.20200101.log"), 10, 30*24*time.Hour)
+	l, err := New(&Options{
+		BaseDir:      dir,
+		Level:        logrus.InfoLevel,
+		PurgeOrphans: true,
+		FilePattern:  "{name}.{pid}.%Y%m%d.log",
+		LinkPattern:  "{name}.log",
+		Routes: append(DefaultRoutes(),
+			Route{Name: "audit", Field: "audit", FilePattern: "audit/%Y%m%d.log", LinkPattern: NoLink}),
+	})
+	assert.Nil(t, err)
+	l.WithField("audit", true).Info("audit entry")
+	assert.Eventually(t, func() bool {
+		return !exists(filepath.Join(dir, "combine.1.20200101.log"))
+	}, time.Second, 10*time.Millisecond)
+	assert.Nil(t, l.Close())
+
+	day := time.Now().Format("20060102")
+	assert.FileExists(t, filepath.Join(dir, "combine."+pid+"."+day+".log"))
+	b, err := os.ReadFile(filepath.Join(dir, "combine.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, "audit entry", lastMessage(string(b)))
+	assert.FileExists(t, filepath.Join(dir, "audit", day+".log"))
+	assert.False(t, exists(filepath.Join(dir, "audit.log")))
+
+	// two routes can't share a file
+	_, err = New(&Options{BaseDir: dir, FilePattern: "app-%Y%m%d.log"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+const (
+	defaultDirMode  os.FileMode = 0755
+	defaultFileMode os.FileMode = 0644
+)
+
+// filePerm how the log dirs and files are created
+type filePerm struct {
+	dirMode  os.FileMode
+	fileMode os.FileMode
+	// chmodDir, chmodFile set the modes regardless of umask, when they are given in Options
+	chmodDir  bool
+	chmodFile bool
+	// uid, gid owner of the dirs and files, -1 means unchanged
+	uid int
+	gid int
+}
+
+func newFilePerm(opt *Options) (*filePerm, error) {
+	p := &filePerm{
+		dirMode:   defaultDirMode,
+		fileMode:  defaultFileMode,
+		chmodDir:  opt.DirMode != 0,
+		chmodFile: opt.FileMode != 0,
+		uid:       -1,
+		gid:       -1,
+	}
+	if p.chmodDir {
+		p.dirMode = opt.DirMode.Perm()
+	}
+	if p.chmodFile {
+		p.fileMode = opt.FileMode.Perm()
+	}
+	if opt.Owner != "" {
+		id, err := strconv.Atoi(opt.Owner)
+		if err != nil {
+			u, err := user.Lookup(opt.Owner)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "unknown log file owner %q", opt.Owner)
+			}
+			id, _ = strconv.Atoi(u.Uid)
+		}
+		p.uid = id
+	}
+	if opt.Group != "" {
+		id, err := strconv.Atoi(opt.Group)
+		if err != nil {
+			g, err := user.LookupGroup(opt.Group)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "unknown log file group %q", opt.Group)
+			}
+			id, _ = strconv.Atoi(g.Gid)
+		}
+		p.gid = id
+	}
+	return p, nil
+}
+
+func (p *filePerm) owned() bool {
+	return p.uid >= 0 || p.gid >= 0
+}
+
+// prepareDir create dir and its parents, apply the permission to dir and check
+// it's writable, so a bad dir fails at init instead of on every entry
+func (p *filePerm) prepareDir(dir string) error {
+	if err := os.MkdirAll(dir, p.dirMode); err != nil {
+		return errors.WithMessagef(err, "create log dir %s error", dir)
+	}
+	if p.chmodDir {
+		if err := os.Chmod(dir, p.dirMode); err != nil {
+			return errors.WithMessagef(err, "change mode of log dir %s error", dir)
+		}
+	}
+	if p.owned() {
+		if err := os.Chown(dir, p.uid, p.gid); err != nil {
+			return errors.WithMessagef(err, "change owner of log dir %s error", dir)
+		}
+	}
+	f, err := os.CreateTemp(dir, ".glog-check-*")
+	if err != nil {
+		return errors.WithMessagef(err, "log dir %s is not writable", dir)
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	return nil
+}
+
+// applyFile apply the permission to a log file just opened
+func (p *filePerm) applyFile(fh *os.File) error {
+	if p.chmodFile {
+		if err := fh.Chmod(p.fileMode); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if p.owned() {
+		if err := fh.Chown(p.uid, p.gid); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// testPerm the default permission
+func testPerm() *filePerm {
+	p, _ := newFilePerm(&Options{})
+	return p
+}
+
+func TestNew_NestedDirAndModes(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "var", "log", "api")
+	l, err := New(&Options{
+		BaseDir:  base,
+		Level:    logrus.InfoLevel,
+		DirMode:  0750,
+		FileMode: 0640,
+		Owner:    strconv.Itoa(os.Getuid()),
+		Group:    strconv.Itoa(os.Getgid()),
+	})
+	assert.Nil(t, err)
+	l.Info("hello")
+	assert.Nil(t, l.Close())
+
+	fi, err := os.Stat(base)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0750), fi.Mode().Perm())
+	files, _ := filepath.Glob(filepath.Join(base, "combine-*.log"))
+	assert.Len(t, files, 1)
+	fi, err = os.Stat(files[0])
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+	// no file left by the writable check
+	check, _ := filepath.Glob(filepath.Join(base, ".glog-check-*"))
+	assert.Empty(t, check)
+}
+
+func TestNew_BadDir(t *testing.T) {
+	// a file where the dir should be
+	file := filepath.Join(t.TempDir(), "file")
+	assert.Nil(t, os.WriteFile(file, nil, 0644))
+	_, err := New(&Options{BaseDir: filepath.Join(file, "logs")})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "create log dir")
+
+	_, err = New(&Options{BaseDir: t.TempDir(), Owner: "no-such-user-glog"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown log file owner")
+}
@@ -0,0 +1,207 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-melodic/glog/internal/formatter"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// formatterKinds number of distinct formatters, TextFormatter and JSONFormatter
+const formatterKinds = 2
+
+// formatterSet create the formatters of the writers, once per name
+type formatterSet struct {
+	opt        *Options
+	names      []string
+	formatters []logrus.Formatter
+}
+
+// index Get index of the formatter of name, empty name means Options.Formatter
+func (s *formatterSet) index(name string) (int, error) {
+	if name == "" {
+		name = s.opt.Formatter
+	}
+	if name == "" {
+		name = TextFormatter
+	}
+	for i, n := range s.names {
+		if n == name {
+			return i, nil
+		}
+	}
+	f, err := newFormatter(s.opt, name)
+	if err != nil {
+		return 0, err
+	}
+	s.names = append(s.names, name)
+	s.formatters = append(s.formatters, f)
+	return len(s.formatters) - 1, nil
+}
+
+func newFormatter(opt *Options, name string) (logrus.Formatter, error) {
+	switch name {
+	case TextFormatter:
+		return &formatter.Formatter{
+			TimeStampLayout:    opt.CustomTimeLayout,
+			Multiline:          opt.Multiline,
+			ContinuationMarker: opt.ContinuationMarker,
+		}, nil
+	case JSONFormatter:
+		layout := time.RFC3339Nano
+		if opt.CustomTimeLayout != "" {
+			layout = opt.CustomTimeLayout
+		}
+		return &logrus.JSONFormatter{TimestampFormat: layout}, nil
+	default:
+		return nil, errors.Errorf("unknown formatter %q", name)
+	}
+}
+
+// pipeline Formatter of the logger and its named loggers. When an entry is being logged
+// (logrus sets entry.Buffer in Entry.log), it's encoded once per distinct formatter and the
+// bytes are written to every log file it's routed to, then logrus writes the bytes of the
+// ExtLoggerWriter formatter to Out. Other calls like entry.String() only format the entry.
+// Writing the log files here, instead of a hook, lets them share the encoded bytes; it means
+// the Formatter of the logrus loggers must stay the pipeline, see Logger.
+type pipeline struct {
+	l *Logger
+}
+
+func (p *pipeline) Format(entry *logrus.Entry) ([]byte, error) {
+	p.l.mu.RLock()
+	defer p.l.mu.RUnlock()
+	out := p.l.out
+	if entry.Buffer == nil || p.l.closed {
+		return out.formatters[0].Format(entry)
+	}
+	if out.sampler != nil && !out.sampler.allow(entry) {
+		return nil, nil
+	}
+	own := out.extFormat
+	if p.l.extFormatter != nil {
+		// entry.Buffer is left to the formatter of SetFormatter
+		own = -1
+	}
+	enc := encoding{entry: entry, formatters: out.formatters, own: own}
+	defer enc.release()
+	if out.files != nil {
+		out.files.route(entry, &enc)
+	}
+	for _, s := range out.sinks {
+		s.write(entry)
+	}
+	if out.ext == io.Discard {
+		return nil, nil
+	}
+	if p.l.extFormatter != nil {
+		return p.l.extFormatter.Format(entry)
+	}
+	return enc.encode(out.extFormat)
+}
+
+// SetFormatter set the formatter of ExtLoggerWriter until the next Reconfigure,
+// the log files keep Options.Formatter and Route.Formatter
+func (l *Logger) SetFormatter(f logrus.Formatter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.extFormatter = f
+}
+
+// bufferPool buffers of the formatters other than the one writing into entry.Buffer
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// encoding an entry encoded lazily, once per formatter
+type encoding struct {
+	entry      *logrus.Entry
+	formatters []logrus.Formatter
+	// own the formatter encoding into entry.Buffer, whose bytes are valid after the
+	// encoding is released, the others encode into pooled buffers
+	own     int
+	done    [formatterKinds]bool
+	encoded [formatterKinds][]byte
+	errs    [formatterKinds]error
+	buffers [formatterKinds]*bytes.Buffer
+}
+
+func (e *encoding) encode(i int) ([]byte, error) {
+	if e.done[i] {
+		return e.encoded[i], e.errs[i]
+	}
+	e.done[i] = true
+	if i == e.own {
+		e.encoded[i], e.errs[i] = e.formatters[i].Format(e.entry)
+		return e.encoded[i], e.errs[i]
+	}
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	e.buffers[i] = buf
+	own := e.entry.Buffer
+	e.entry.Buffer = buf
+	e.encoded[i], e.errs[i] = e.formatters[i].Format(e.entry)
+	e.entry.Buffer = own
+	return e.encoded[i], e.errs[i]
+}
+
+// release the pooled buffers, the bytes encoded into them are invalid after it
+func (e *encoding) release() {
+	for i, buf := range e.buffers {
+		if buf != nil {
+			bufferPool.Put(buf)
+			e.buffers[i] = nil
+		}
+	}
+}
+
+// route write the entry to the log files it matches, encoded by the formatter of each file
+func (h *fileRouter) route(entry *logrus.Entry, enc *encoding) {
+	// key of Options.DedupTimeout, computed once for all files
+	var key string
+	for _, s := range h.sinks {
+		if !s.match(entry) {
+			continue
+		}
+		if s.dedup != nil {
+			if key == "" {
+				key = dedupKey(entry)
+			}
+			h.routeDedup(s, entry, key, enc)
+			continue
+		}
+		b, err := enc.encode(s.format)
+		if err != nil {
+			h.formatError(s, err)
+			continue
+		}
+		h.write(s, b)
+	}
+}
+
+func (h *fileRouter) formatError(s *fileSink, err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "[GINLOG]Format entry of %s log error. %v\n", s.name, err)
+}
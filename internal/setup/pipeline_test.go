@@ -0,0 +1,149 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPipeline_EncodeOncePerFormatter(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	l, err := New(&Options{
+		Level:           logrus.InfoLevel,
+		BaseDir:         dir,
+		ExtLoggerWriter: []io.Writer{&out},
+		Routes: append(DefaultRoutes(),
+			Route{Name: "json", Formatter: JSONFormatter},
+			Route{Name: "audit", Formatter: JSONFormatter}),
+	})
+	assert.Nil(t, err)
+	assert.Len(t, l.out.formatters, 2)
+	text := &countFormatter{Formatter: l.out.formatters[0]}
+	json := &countFormatter{Formatter: l.out.formatters[1]}
+	l.out.formatters[0], l.out.formatters[1] = text, json
+
+	l.Error("to all")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&text.n))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&json.n))
+	assert.Nil(t, l.Close())
+
+	read := func(name string) string {
+		b, err := os.ReadFile(dir + "/latest-" + name + "-log")
+		assert.Nil(t, err)
+		return string(b)
+	}
+	assert.Equal(t, out.String(), read("combine"))
+	assert.Equal(t, out.String(), read("error"))
+	assert.True(t, strings.HasPrefix(read("json"), `{"level":"error","msg":"to all"`))
+	assert.Equal(t, read("json"), read("audit"))
+
+	// JSON on console, text in files
+	out.Reset()
+	l, err = New(&Options{
+		Level:           logrus.InfoLevel,
+		BaseDir:         dir,
+		ExtLoggerWriter: []io.Writer{&out},
+		ExtFormatter:    JSONFormatter,
+	})
+	assert.Nil(t, err)
+	l.Info("mixed")
+	assert.Nil(t, l.Close())
+	assert.Contains(t, out.String(), `"msg":"mixed"`)
+	assert.Contains(t, read("combine"), "[INFO]mixed\n")
+
+	_, err = New(&Options{BaseDir: dir, Routes: []Route{{Name: "xml", Formatter: "xml"}}})
+	assert.Error(t, err)
+}
+
+// benchmarkLogger log b.N entries to the log files of opt in a temp dir
+func benchmarkLogger(b *testing.B, opt *Options, log func(l *Logger)) {
+	opt.BaseDir = b.TempDir()
+	opt.Level = logrus.InfoLevel
+	l, err := New(opt)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer l.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		log(l)
+	}
+}
+
+func BenchmarkLogger_Text(b *testing.B) {
+	benchmarkLogger(b, &Options{}, func(l *Logger) {
+		l.Info("benchmark entry")
+	})
+}
+
+func BenchmarkLogger_TextWithCaller(b *testing.B) {
+	benchmarkLogger(b, &Options{ReportCaller: true}, func(l *Logger) {
+		l.Info("benchmark entry")
+	})
+}
+
+func BenchmarkLogger_JSON(b *testing.B) {
+	benchmarkLogger(b, &Options{Formatter: JSONFormatter}, func(l *Logger) {
+		l.WithField("user", "alice").Info("benchmark entry")
+	})
+}
+
+// every entry goes to two files and Out with two formatters
+func BenchmarkLogger_FanOut(b *testing.B) {
+	opt := &Options{
+		ExtLoggerWriter: []io.Writer{io.Discard},
+		ExtFormatter:    JSONFormatter,
+	}
+	err := errors.New("benchmark error")
+	benchmarkLogger(b, opt, func(l *Logger) {
+		l.WithError(err).WithField("elapsed", time.Millisecond).Error("benchmark entry")
+	})
+}
+
+func TestLogger_SetFormatter(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	opt := &Options{Level: logrus.InfoLevel, BaseDir: dir, LogFilePrefix: "f", ExtLoggerWriter: []io.Writer{&out}}
+	l, err := New(opt)
+	assert.Nil(t, err)
+	defer l.Close()
+	l.SetFormatter(&logrus.JSONFormatter{})
+	l.Info("custom")
+	// only ExtLoggerWriter uses it, the log files are still written
+	assert.True(t, strings.HasPrefix(out.String(), `{"level":"info","msg":"custom"`), out.String())
+	b, err := os.ReadFile(dir + "/latest-combine-f-log")
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "[INFO]custom")
+	assert.NotContains(t, string(b), "{")
+
+	// Reconfigure applies Options.ExtFormatter again
+	out.Reset()
+	assert.Nil(t, l.Reconfigure(opt))
+	l.Info("text again")
+	assert.Contains(t, out.String(), "[INFO]text again")
+}
@@ -0,0 +1,159 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"fmt"
+	"github.com/lestrrat-go/strftime"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// strftimeVerbs matches the conversion verbs of strftime pattern
+var strftimeVerbs = regexp.MustCompile(`(%[%+A-Za-z])+`)
+
+// rotateFile a log file named by a strftime pattern, a new file is opened when the
+// name of current time changes, e.g. every day for "combine-%Y%m%d.log". It replaces
+// lestrrat-go/file-rotatelogs, whose MaxAge and RotationCount can't be combined and which
+// removes files on its own, so retention by size and DirQuota are left to the janitor.
+type rotateFile struct {
+	mu      sync.Mutex
+	pattern *strftime.Strftime
+	// glob matches all files of the pattern
+	glob string
+	// linkName symlink to the current file, empty means no symlink
+	linkName string
+	perm     *filePerm
+	fh       *os.File
+	name     string
+	// rotated called after a new file is opened
+	rotated func()
+}
+
+func newRotateFile(pattern, linkName string, perm *filePerm) (*rotateFile, error) {
+	pattern = filepath.Clean(pattern)
+	p, err := strftime.New(pattern)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid file name pattern %q", pattern)
+	}
+	return &rotateFile{
+		pattern:  p,
+		glob:     strftimeVerbs.ReplaceAllString(pattern, "*"),
+		linkName: linkName,
+		perm:     perm,
+	}, nil
+}
+
+func (w *rotateFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if name := w.pattern.FormatString(time.Now()); w.fh == nil || name != w.name {
+		if err := w.open(name); err != nil {
+			return 0, err
+		}
+	}
+	return w.fh.Write(p)
+}
+
+// Reopen open the file of current time again, even if its name is not changed
+func (w *rotateFile) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.open(w.pattern.FormatString(time.Now()))
+}
+
+// open MUST hold mu
+func (w *rotateFile) open(name string) error {
+	// the dir may be removed since last open
+	if err := os.MkdirAll(filepath.Dir(name), w.perm.dirMode); err != nil {
+		return errors.WithStack(err)
+	}
+	fh, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, w.perm.fileMode)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.perm.applyFile(fh); err != nil {
+		_ = fh.Close()
+		return err
+	}
+	if w.fh != nil {
+		_ = w.fh.Close()
+	}
+	w.fh, w.name = fh, name
+	if w.linkName != "" {
+		if err := link(name, w.linkName, w.perm); err != nil {
+			// the log file works without link, don't fail the write
+			_, _ = fmt.Fprintf(os.Stderr, "[GINLOG]Link log file %s error. %v\n", name, err)
+		}
+	}
+	if w.rotated != nil {
+		w.rotated()
+	}
+	return nil
+}
+
+// current Get name of the file being written, empty if no file is opened
+func (w *rotateFile) current() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.name
+}
+
+// Sync commit the file being written to disk
+func (w *rotateFile) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fh == nil {
+		return nil
+	}
+	return errors.WithStack(w.fh.Sync())
+}
+
+func (w *rotateFile) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fh == nil {
+		return nil
+	}
+	err := w.fh.Close()
+	w.fh = nil
+	return errors.WithStack(err)
+}
+
+// link replace linkName with a symlink to name atomically
+func link(name, linkName string, perm *filePerm) error {
+	target := name
+	if rel, err := filepath.Rel(filepath.Dir(linkName), name); err == nil {
+		target = rel
+	}
+	tmp := linkName + "_symlink"
+	_ = os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return errors.WithStack(err)
+	}
+	if perm.owned() {
+		if err := os.Lchown(tmp, perm.uid, perm.gid); err != nil {
+			_ = os.Remove(tmp)
+			return errors.WithStack(err)
+		}
+	}
+	return errors.WithStack(os.Rename(tmp, linkName))
+}
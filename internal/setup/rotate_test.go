@@ -0,0 +1,37 @@
+package setup
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotateFile(t *testing.T) {
+	dir := t.TempDir()
+	w, err := newRotateFile(dir+"/app-%Y%m%d.log", dir+"/latest-app-log", testPerm())
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "app-*.log"), w.glob)
+	rotated := 0
+	w.rotated = func() { rotated++ }
+
+	_, err = w.Write([]byte("first\n"))
+	assert.Nil(t, err)
+	_, err = w.Write([]byte("second\n"))
+	assert.Nil(t, err)
+	name := filepath.Join(dir, "app-"+time.Now().Format("20060102")+".log")
+	assert.Equal(t, name, w.current())
+	assert.Equal(t, 1, rotated)
+
+	// the link is relative to its dir
+	target, err := os.Readlink(dir + "/latest-app-log")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Base(name), target)
+	b, err := os.ReadFile(dir + "/latest-app-log")
+	assert.Nil(t, err)
+	assert.Equal(t, "first\nsecond\n", string(b))
+
+	assert.Nil(t, w.Close())
+	assert.Nil(t, w.Close())
+}
@@ -0,0 +1,279 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"fmt"
+	"github.com/gin-melodic/glog/internal/formatter"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Route send the entries it matches to the rotated log file of Name.
+// An entry is matched when all of the given conditions hold.
+type Route struct {
+	// Name of the log file "<prefix>-<Name>-%Y%m%d.log", routes with the same name share the file.
+	// The retention, patterns and formatter of the file are set by the first of them, the others
+	// leave them empty or set the same values.
+	Name string
+	// Levels of the entries, empty means all levels
+	Levels []logrus.Level
+	// Field the entries carry, like "audit" in logger.WithField("audit", true) or a typed
+	// field like glog.Bool("audit", true), empty means no check
+	Field string
+	// Match custom predicate of the entries, nil means no check
+	Match func(entry *logrus.Entry) bool
+	// MaxAge remove the log files older than it, default Options.RotateDuration
+	MaxAge time.Duration
+	// MaxFiles keep at most MaxFiles log files, 0 means no limit
+	MaxFiles int
+	// MaxSize keep the total size of the log files under MaxSize bytes, 0 means no limit.
+	// The file being written is never removed.
+	MaxSize int64
+	// FilePattern strftime pattern of the log file name relative to the log dir,
+	// default Options.FilePattern. See PrefixPlaceholder for the placeholders.
+	FilePattern string
+	// LinkPattern name of the symlink to the current log file, NoLink disables it,
+	// default Options.LinkPattern
+	LinkPattern string
+	// Formatter TextFormatter or JSONFormatter of the log file, default Options.Formatter
+	Formatter string
+}
+
+// DefaultRoutes all entries go to the combine log, and Error, Fatal, Panic entries go to the error log too
+func DefaultRoutes() []Route {
+	return []Route{
+		{Name: "combine"},
+		{Name: "error", Levels: []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}},
+	}
+}
+
+func (r *Route) match(entry *logrus.Entry) bool {
+	if len(r.Levels) > 0 {
+		found := false
+		for _, level := range r.Levels {
+			if level == entry.Level {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.Field != "" && !hasField(entry, r.Field) {
+		return false
+	}
+	return r.Match == nil || r.Match(entry)
+}
+
+// hasField report whether the entry carries the field key, in entry.Data or its typed fields
+func hasField(entry *logrus.Entry, key string) bool {
+	if _, ok := entry.Data[key]; ok {
+		return true
+	}
+	fs, _ := entry.Data[formatter.FieldsKey].(formatter.Fields)
+	for i := range fs {
+		if fs[i].Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// conflict Get an error if other shares the file of r but sets its retention, names or
+// formatter differently, they are decided by the first route of the name
+func (r *Route) conflict(other *Route) error {
+	check := func(field string, a, b interface{}, set bool) error {
+		if set && a != b {
+			return errors.Errorf("routes %s set different %s %q and %q", r.Name, field, fmt.Sprint(a), fmt.Sprint(b))
+		}
+		return nil
+	}
+	return combineErrors(
+		check("MaxAge", r.MaxAge, other.MaxAge, other.MaxAge != 0),
+		check("MaxFiles", r.MaxFiles, other.MaxFiles, other.MaxFiles != 0),
+		check("MaxSize", r.MaxSize, other.MaxSize, other.MaxSize != 0),
+		check("FilePattern", r.FilePattern, other.FilePattern, other.FilePattern != ""),
+		check("LinkPattern", r.LinkPattern, other.LinkPattern, other.LinkPattern != ""),
+		check("Formatter", r.Formatter, other.Formatter, other.Formatter != ""),
+	)
+}
+
+// fileSink a rotated log file and the routes lead to it,
+// the retention and file names are decided by the first route of the name
+type fileSink struct {
+	name   string
+	names  *sinkNames
+	routes []Route
+	writer *rotateFile
+	// format index of the formatter in formatterSet, formatter is the formatter itself
+	format    int
+	formatter logrus.Formatter
+	maxAge    time.Duration
+	maxFiles  int
+	maxSize   int64
+	// degradation state when the file can't be written
+	degradation degradation
+	// dedup nil if Options.DedupTimeout is 0
+	dedup *dedup
+}
+
+func (s *fileSink) match(entry *logrus.Entry) bool {
+	for i := range s.routes {
+		if s.routes[i].match(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileRouter write the entries to the log files they are routed to
+type fileRouter struct {
+	sinks    []*fileSink
+	janitor  *janitor
+	fallback *fallback
+}
+
+// newFileRouter create the log files of opt.Routes, or DefaultRoutes if it's empty
+func newFileRouter(opt *Options, formatters *formatterSet, perm *filePerm) (*fileRouter, error) {
+	routes := opt.Routes
+	if len(routes) == 0 {
+		routes = DefaultRoutes()
+	}
+	// rotate max age
+	defaultMaxAge := 7 * 24 * time.Hour
+	if opt.RotateDuration > 0 {
+		defaultMaxAge = opt.RotateDuration
+	}
+	fw := opt.FallbackWriter
+	if fw == nil {
+		fw = os.Stderr
+	}
+	h := &fileRouter{
+		fallback: &fallback{w: fw, onWriteError: opt.OnWriteError},
+	}
+	byName := map[string]*fileSink{}
+	byFile := map[string]string{}
+	for _, r := range routes {
+		if r.Name == "" || strings.ContainsAny(r.Name, `/\`) {
+			_ = h.close()
+			return nil, errors.Errorf("invalid route name %q", r.Name)
+		}
+		if s, ok := byName[r.Name]; ok {
+			if err := s.routes[0].conflict(&r); err != nil {
+				_ = h.close()
+				return nil, err
+			}
+			s.routes = append(s.routes, r)
+			continue
+		}
+		maxAge := defaultMaxAge
+		if r.MaxAge > 0 {
+			maxAge = r.MaxAge
+		}
+		names, err := newSinkNames(opt, &r)
+		if err != nil {
+			_ = h.close()
+			return nil, err
+		}
+		format, err := formatters.index(r.Formatter)
+		if err != nil {
+			_ = h.close()
+			return nil, errors.WithMessagef(err, "route %s", r.Name)
+		}
+		if other, ok := byFile[names.file]; ok {
+			_ = h.close()
+			return nil, errors.Errorf("routes %s and %s write the same file %s", other, r.Name, names.file)
+		}
+		byFile[names.file] = r.Name
+		link := ""
+		if names.link != "" {
+			link = filepath.Join(opt.BaseDir, names.link)
+		}
+		w, err := newRotateFile(filepath.Join(opt.BaseDir, names.file), link, perm)
+		if err != nil {
+			_ = h.close()
+			return nil, errors.WithMessagef(err, "rotate %s log error", r.Name)
+		}
+		s := &fileSink{
+			name:      r.Name,
+			names:     names,
+			routes:    []Route{r},
+			writer:    w,
+			format:    format,
+			formatter: formatters.formatters[format],
+			degradation: degradation{
+				minBackoff: minReopenBackoff,
+				maxBackoff: maxReopenBackoff,
+			},
+		}
+		// the files rotated externally are removed by the external tool too
+		if !opt.ExternalRotate {
+			s.maxAge, s.maxFiles, s.maxSize = maxAge, r.MaxFiles, r.MaxSize
+		}
+		if opt.DedupTimeout > 0 {
+			s.dedup = &dedup{timeout: opt.DedupTimeout}
+		}
+		byName[r.Name] = s
+		h.sinks = append(h.sinks, s)
+	}
+	h.janitor = newJanitor(opt, h.sinks)
+	return h, nil
+}
+
+// reopen all log files, it goes on when one of them fails
+func (h *fileRouter) reopen() error {
+	var errs []error
+	for _, s := range h.sinks {
+		if err := s.writer.Reopen(); err != nil {
+			errs = append(errs, errors.WithMessagef(err, "reopen %s log error", s.name))
+		}
+	}
+	return combineErrors(errs...)
+}
+
+func (h *fileRouter) sync() error {
+	var errs []error
+	for _, s := range h.sinks {
+		if err := s.writer.Sync(); err != nil {
+			errs = append(errs, errors.WithMessagef(err, "sync %s log error", s.name))
+		}
+	}
+	return combineErrors(errs...)
+}
+
+// close all log files, it goes on when one of them fails
+func (h *fileRouter) close() error {
+	if h.janitor != nil {
+		h.janitor.stop()
+	}
+	var errs []error
+	for _, s := range h.sinks {
+		if s.dedup != nil {
+			h.stopDedup(s)
+		}
+		if err := s.writer.Close(); err != nil {
+			errs = append(errs, errors.WithMessagef(err, "close %s log error", s.name))
+		}
+	}
+	return combineErrors(errs...)
+}
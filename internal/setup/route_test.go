@@ -0,0 +1,92 @@
+package setup
+
+import (
+	"github.com/gin-melodic/glog/internal/formatter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_Routes(t *testing.T) {
+	const dir = "./test-route-logs"
+	l, err := New(&Options{
+		Level:         logrus.DebugLevel,
+		BaseDir:       dir,
+		LogFilePrefix: "test",
+		Routes: append(DefaultRoutes(),
+			Route{Name: "warn", Levels: []logrus.Level{logrus.WarnLevel}},
+			Route{Name: "audit", Field: "audit", MaxAge: 180 * 24 * time.Hour},
+			Route{Name: "debug", Levels: []logrus.Level{logrus.DebugLevel, logrus.TraceLevel}},
+			Route{Name: "debug", Match: func(entry *logrus.Entry) bool {
+				return strings.HasPrefix(entry.Message, "verbose")
+			}},
+		),
+	})
+	assert.Nil(t, err)
+	l.Debug("debug entry")
+	l.Info("verbose info entry")
+	l.Warn("warn entry")
+	l.WithField("audit", "alice").Info("audit entry")
+	l.LogFields(0, logrus.InfoLevel, "typed audit entry", []formatter.Field{
+		{Key: "audit", Type: formatter.BoolType, Integer: 1},
+	})
+	l.LogFields(0, logrus.InfoLevel, "typed entry", []formatter.Field{
+		{Key: "user", Type: formatter.StringType, String: "bob"},
+	})
+	l.Error("error entry")
+	assert.Nil(t, l.Close())
+
+	read := func(name string) string {
+		b, err := os.ReadFile(dir + "/latest-" + name + "-test-log")
+		assert.Nil(t, err)
+		return string(b)
+	}
+	assert.Equal(t, 7, strings.Count(read("combine"), "\n"))
+	assert.Equal(t, "error entry", lastMessage(read("error")))
+	assert.Equal(t, "warn entry", lastMessage(read("warn")))
+	audit := read("audit")
+	assert.Equal(t, 2, strings.Count(audit, "\n"))
+	assert.Contains(t, audit, "]audit entry")
+	assert.Contains(t, audit, "typed audit entry")
+	debug := read("debug")
+	assert.Equal(t, 2, strings.Count(debug, "\n"))
+	assert.Contains(t, debug, "debug entry")
+	assert.Contains(t, debug, "verbose info entry")
+
+	// invalid route
+	_, err = New(&Options{BaseDir: dir, Routes: []Route{{Name: "../escape"}}})
+	assert.Error(t, err)
+	assert.Nil(t, os.RemoveAll(dir))
+}
+
+// lastMessage the message of the only line in content
+func lastMessage(content string) string {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	if len(lines) != 1 {
+		return ""
+	}
+	return lines[0][strings.LastIndexByte(lines[0], ']')+1:]
+}
+
+func TestNew_RouteConflict(t *testing.T) {
+	day := 24 * time.Hour
+	_, err := New(&Options{Level: logrus.InfoLevel, BaseDir: t.TempDir(), Routes: []Route{
+		{Name: "audit", Field: "audit", MaxAge: 180 * day},
+		{Name: "audit", Levels: []logrus.Level{logrus.ErrorLevel}, MaxAge: 7 * day, Formatter: JSONFormatter},
+	}})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `routes audit set different MaxAge "4320h0m0s" and "168h0m0s"`)
+	assert.Contains(t, err.Error(), `Formatter "" and "json"`)
+
+	// the same values, or none
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: t.TempDir(), Routes: []Route{
+		{Name: "audit", Field: "audit", MaxAge: 180 * day},
+		{Name: "audit", Levels: []logrus.Level{logrus.ErrorLevel}, MaxAge: 180 * day},
+		{Name: "audit", Field: "security"},
+	}})
+	assert.Nil(t, err)
+	assert.Nil(t, l.Close())
+}
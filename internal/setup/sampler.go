@@ -0,0 +1,187 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// SuppressedKey field of the summary entries of Sampling, the number of suppressed entries
+const SuppressedKey = "glog.suppressed"
+
+// defaultSampleTick window of Sampling.Tick
+const defaultSampleTick = time.Second
+
+// Sampling limit repetitive entries, like a flapping dependency logging the same error
+// thousands of times per second. Entries with the same level and message are counted in
+// every Tick window: the first First entries are logged, then every Thereafter-th one.
+// At the end of the window a summary "suppressed K similar entries" is logged for
+// each message with suppressed entries.
+type Sampling struct {
+	// Tick length of the window, default 1 second
+	Tick time.Duration
+	// Levels rate of each sampled level, the levels not in it are not sampled
+	Levels map[logrus.Level]SampleRate
+}
+
+// SampleRate log the First entries of a window, then every Thereafter-th entry,
+// Thereafter 0 means none after First
+type SampleRate struct {
+	First      int
+	Thereafter int
+}
+
+func (s *Sampling) check() error {
+	if s.Tick < 0 {
+		return errors.Errorf("invalid sampling tick %s", s.Tick)
+	}
+	for level, rate := range s.Levels {
+		if rate.First < 0 || rate.Thereafter < 0 {
+			return errors.Errorf("invalid sampling rate %d/%d of level %s", rate.First, rate.Thereafter, level)
+		}
+	}
+	return nil
+}
+
+// sampleKey entries counted together
+type sampleKey struct {
+	level   logrus.Level
+	message string
+}
+
+type sampleCount struct {
+	n          int
+	suppressed int
+}
+
+// sampler count the entries of the sampled levels in the current window
+type sampler struct {
+	tick   time.Duration
+	levels map[logrus.Level]SampleRate
+	mu     sync.Mutex
+	counts map[sampleKey]*sampleCount
+	// logger logs the summaries
+	logger   *logrus.Logger
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// newSampler create the sampler of opt, nil if sampling is off
+func newSampler(opt *Sampling) (*sampler, error) {
+	if opt == nil || len(opt.Levels) == 0 {
+		return nil, nil
+	}
+	if err := opt.check(); err != nil {
+		return nil, err
+	}
+	tick := opt.Tick
+	if tick == 0 {
+		tick = defaultSampleTick
+	}
+	levels := make(map[logrus.Level]SampleRate, len(opt.Levels))
+	for level, rate := range opt.Levels {
+		levels[level] = rate
+	}
+	return &sampler{
+		tick:   tick,
+		levels: levels,
+		counts: map[sampleKey]*sampleCount{},
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// allow Tell whether the entry is logged, the summaries are always logged
+func (s *sampler) allow(entry *logrus.Entry) bool {
+	rate, ok := s.levels[entry.Level]
+	if !ok {
+		return true
+	}
+	if _, ok := entry.Data[SuppressedKey]; ok {
+		return true
+	}
+	key := sampleKey{level: entry.Level, message: entry.Message}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.counts[key]
+	if c == nil {
+		c = &sampleCount{}
+		s.counts[key] = c
+	}
+	c.n++
+	if c.n <= rate.First || (rate.Thereafter > 0 && (c.n-rate.First)%rate.Thereafter == 0) {
+		return true
+	}
+	c.suppressed++
+	return false
+}
+
+// start the windows, summaries are logged by logger
+func (s *sampler) start(logger *logrus.Logger) {
+	s.logger = logger
+	s.wg.Add(1)
+	go s.run()
+}
+
+func (s *sampler) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.done:
+			s.flush()
+			return
+		}
+	}
+}
+
+// flush end the window and log the summaries of it
+func (s *sampler) flush() {
+	s.mu.Lock()
+	counts := s.counts
+	s.counts = make(map[sampleKey]*sampleCount, len(counts))
+	s.mu.Unlock()
+	for key, c := range counts {
+		if c.suppressed == 0 {
+			continue
+		}
+		// logging at PanicLevel panics
+		level := key.level
+		if level == logrus.PanicLevel {
+			level = logrus.ErrorLevel
+		}
+		s.logger.WithField(SuppressedKey, c.suppressed).Logf(level,
+			"[GINLOG]Suppressed %d similar entries in the last %s: %s", c.suppressed, s.tick, key.message)
+	}
+}
+
+// stop the windows, the summaries of the last window are logged, it's safe to call more than once
+func (s *sampler) stop() {
+	if s == nil {
+		return
+	}
+	s.stopOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+	})
+}
@@ -0,0 +1,101 @@
+package setup
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSampler_Allow(t *testing.T) {
+	s, err := newSampler(&Sampling{Levels: map[logrus.Level]SampleRate{
+		logrus.ErrorLevel: {First: 2, Thereafter: 3},
+		logrus.WarnLevel:  {First: 1},
+	}})
+	assert.Nil(t, err)
+	entry := func(level logrus.Level, msg string) *logrus.Entry {
+		return &logrus.Entry{Level: level, Message: msg, Data: logrus.Fields{}}
+	}
+	var allowed []bool
+	for i := 0; i < 9; i++ {
+		allowed = append(allowed, s.allow(entry(logrus.ErrorLevel, "flap")))
+	}
+	assert.Equal(t, []bool{true, true, false, false, true, false, false, true, false}, allowed)
+	// counted by message and level
+	assert.True(t, s.allow(entry(logrus.ErrorLevel, "other")))
+	assert.True(t, s.allow(entry(logrus.WarnLevel, "flap")))
+	assert.False(t, s.allow(entry(logrus.WarnLevel, "flap")))
+	// levels not sampled
+	for i := 0; i < 5; i++ {
+		assert.True(t, s.allow(entry(logrus.InfoLevel, "flap")))
+	}
+	// summaries
+	summary := entry(logrus.WarnLevel, "flap")
+	summary.Data[SuppressedKey] = 1
+	assert.True(t, s.allow(summary))
+
+	_, err = newSampler(&Sampling{Levels: map[logrus.Level]SampleRate{logrus.ErrorLevel: {First: -1}}})
+	assert.NotNil(t, err)
+	s, err = newSampler(&Sampling{})
+	assert.Nil(t, err)
+	assert.Nil(t, s)
+}
+
+func TestLogger_Sampling(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: dir, LogFilePrefix: "s",
+		ExtLoggerWriter: []io.Writer{&out},
+		// the window ends on Close
+		Sampling: &Sampling{Tick: time.Hour, Levels: map[logrus.Level]SampleRate{
+			logrus.ErrorLevel: {First: 2, Thereafter: 10},
+		}}})
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				l.Error("connection refused")
+				l.Info("retry")
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Nil(t, l.Close())
+
+	b, err := os.ReadFile(dir + "/latest-error-s-log")
+	assert.Nil(t, err)
+	// 2 first, then every 10th of the other 98, and the summary
+	assert.Equal(t, 12, strings.Count(string(b), "connection refused"))
+	assert.Contains(t, string(b), "Suppressed 89 similar entries in the last 1h0m0s: connection refused")
+	assert.Equal(t, 12, strings.Count(out.String(), "connection refused"))
+	assert.Equal(t, 100, strings.Count(out.String(), "retry"))
+}
+
+func TestLogger_SamplingWindow(t *testing.T) {
+	var out lockedBuffer
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: t.TempDir(), ExtLoggerWriter: []io.Writer{&out},
+		Sampling: &Sampling{Tick: 50 * time.Millisecond, Levels: map[logrus.Level]SampleRate{
+			logrus.WarnLevel: {First: 1},
+		}}})
+	assert.Nil(t, err)
+	defer l.Close()
+	for i := 0; i < 5; i++ {
+		l.Warn("slow query")
+	}
+	// the summary is logged at the end of the window, then the counter starts over
+	assert.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "Suppressed 4 similar entries")
+	}, time.Second, 10*time.Millisecond)
+	l.Warn("slow query")
+	assert.Eventually(t, func() bool {
+		return strings.Count(out.String(), "slow query") == 3
+	}, time.Second, 10*time.Millisecond)
+}
@@ -18,16 +18,15 @@ package setup
 
 import (
 	"github.com/gin-melodic/glog/internal/formatter"
-	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
 	"github.com/pkg/errors"
-	"github.com/rifflock/lfshook"
 	"github.com/sirupsen/logrus"
 	"io"
 	"os"
+	"sync"
 	"time"
 )
 
-// Options logger setup options, BaseDir is requirement, RewriteDuration default 7 days
+// Options logger setup options, BaseDir is requirement, RotateDuration default 7 days
 type Options struct {
 	BaseDir string
 
@@ -38,96 +37,315 @@ type Options struct {
 	// ExtLoggerWriter write to other output, like os.Stdout in dev. Default write to logFile
 	ExtLoggerWriter  []io.Writer
 	CustomTimeLayout string
+	// Multiline how line breaks in message are written, default keep them as they are
+	Multiline          formatter.MultilineMode
+	ContinuationMarker string
+	// NamedLevels level of named loggers by pattern, see Logger.Named
+	NamedLevels map[string]logrus.Level
+	// Formatter TextFormatter or JSONFormatter, default is TextFormatter
+	Formatter string
+	// ExtFormatter formatter of ExtLoggerWriter, default is Formatter
+	ExtFormatter string
+	// Routes decide which entries go to which log file, default is DefaultRoutes
+	Routes []Route
+	// PurgeOrphans also remove the log files of other prefixes (left by previous
+	// configurations) out of the max age of the route with the same name.
+	// Don't enable it if loggers with different prefixes share BaseDir.
+	PurgeOrphans bool
+	// DirQuota limit total bytes of the log files of all routes, the oldest files
+	// are removed ahead of retention when it's exceeded, 0 means no limit
+	DirQuota int64
+	// FallbackWriter takes the entries when log files can't be written, default os.Stderr.
+	// The failed files are re-opened with exponential backoff.
+	FallbackWriter io.Writer
+	// OnWriteError called when a log file can't be written, see Logger.Degraded.
+	// It's called on its own goroutine, one error at a time, and may log through the logger.
+	OnWriteError func(err error)
+	// DirMode, FileMode permission of BaseDir and the log files regardless of umask,
+	// default 0755 and 0644 with umask applied
+	DirMode  os.FileMode
+	FileMode os.FileMode
+	// Owner, Group of BaseDir and the log files, user/group name or numeric id, default unchanged
+	Owner string
+	Group string
+	// FilePattern strftime pattern of the log file names relative to BaseDir, with placeholders
+	// like "{host}/{prefix}-{name}-%Y%m%d.log", default "{prefix}-{name}-%Y%m%d.log"
+	FilePattern string
+	// LinkPattern name of the symlinks to the current log files relative to BaseDir,
+	// NoLink disables them, default "latest-{name}-{prefix}-log"
+	LinkPattern string
+	// ExternalRotate the log files are rotated by external tool like logrotate. They have
+	// fixed names, default "{prefix}-{name}.log" without symlink, and are re-opened by Reopen.
+	// They are never removed: the retention of Routes and DirQuota are not applied.
+	ExternalRotate bool
+	// Sampling limit repetitive entries by level and message, nil means no sampling
+	Sampling *Sampling
+	// DedupTimeout collapse identical consecutive entries (level, message and fields) of a
+	// log file within it into a line "last message repeated N times", 0 means no collapse
+	DedupTimeout time.Duration
+	// Syslog send the entries to syslog too, nil means no syslog
+	Syslog *SyslogOptions
+	// Journald send the entries to systemd-journald too, nil means no journald
+	Journald *JournaldOptions
 }
 
+// Formatter names of Options.Formatter
+const (
+	TextFormatter = "text"
+	JSONFormatter = "json"
+)
+
+// Logger a logrus.Logger writing to the outputs of Options. The log files, syslog and
+// journald are written by its Formatter, so don't assign Formatter of the embedded
+// logrus.Logger, which would turn them off. SetFormatter changes the formatter of
+// ExtLoggerWriter only.
 type Logger struct {
 	*logrus.Logger
-	options        *Options
-	logFileHandler *os.File
-	logWriters     []*rotatelogs.RotateLogs
+	// mu guard options, out, closed and extFormatter, entries being written hold the read lock
+	mu      sync.RWMutex
+	options *Options
+	out     *outputs
+	// closed entries go to the fallback writer after Close
+	closed bool
+	// extFormatter set by SetFormatter, nil means the formatter of Options.ExtFormatter
+	extFormatter logrus.Formatter
+	named        namedLoggers
+}
+
+// outputs writers created from Options, Reconfigure swap them as a whole
+type outputs struct {
+	// extMu serialize writes of the base logger and its named loggers,
+	// every logrus.Logger only guard its own writes.
+	extMu sync.Mutex
+	// formatters distinct formatters of the writers, the first one is Options.Formatter
+	formatters []logrus.Formatter
+	// extFormat index of the formatter of ext in formatters
+	extFormat int
+	ext       io.Writer
+	files     *fileRouter
+	fallback  *fallback
+	// sampler nil if Options.Sampling is off
+	sampler *sampler
+	// sinks syslog and journald
+	sinks []entrySink
+}
+
+// outWriter Out of the logger and its named loggers, writes to Options.ExtLoggerWriter
+// of the current outputs, the entries are discarded if there is none
+type outWriter struct {
+	l *Logger
+}
+
+func (w *outWriter) Write(p []byte) (int, error) {
+	w.l.mu.RLock()
+	defer w.l.mu.RUnlock()
+	if len(p) == 0 {
+		// sampled out or no ExtLoggerWriter
+		return 0, nil
+	}
+	if w.l.closed {
+		w.l.out.fallback.write(p)
+		return len(p), nil
+	}
+	w.l.out.extMu.Lock()
+	defer w.l.out.extMu.Unlock()
+	return w.l.out.ext.Write(p)
 }
 
 func New(opt *Options) (*Logger, error) {
-	mutePipe, err := os.OpenFile(os.DevNull, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
+	out, err := newOutputs(opt)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, err
 	}
-	writers := io.MultiWriter(append(opt.ExtLoggerWriter, mutePipe)...)
+	return newLogger(opt, out), nil
+}
+
+// NewConsole create a logger only writes to w, without log files.
+// Reconfigure it to add log files.
+func NewConsole(w io.Writer, level logrus.Level) *Logger {
+	opt := &Options{
+		Level:           level,
+		ReportCaller:    true,
+		ExtLoggerWriter: []io.Writer{w},
+	}
+	f, _ := newFormatter(opt, TextFormatter)
+	return newLogger(opt, &outputs{formatters: []logrus.Formatter{f}, ext: w, fallback: &fallback{w: os.Stderr}})
+}
+
+func newLogger(opt *Options, out *outputs) *Logger {
 	lc := logrus.New()
 	lc.SetLevel(opt.Level)
 	lc.SetReportCaller(opt.ReportCaller)
-	lc.SetFormatter(&formatter.Formatter{TimeStampLayout: opt.CustomTimeLayout})
-	lc.Out = writers
+	logger := &Logger{
+		Logger:  lc,
+		options: opt,
+		out:     out,
+		named:   namedLoggers{levels: copyLevels(opt.NamedLevels)},
+	}
+	lc.Out = &outWriter{l: logger}
+	lc.Formatter = &pipeline{l: logger}
+	lc.AddHook(callerHook{})
+	out.start(logger)
+	return logger
+}
+
+// Reconfigure apply opt to the running logger. New writers are created first and
+// swapped in atomically, entries being written are finished by the old writers
+// before they are closed. The logger keeps the old configuration if opt is invalid.
+// A closed logger writes to the new writers again.
+func (l *Logger) Reconfigure(opt *Options) error {
+	out, err := newOutputs(opt)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	old := l.out
+	l.out = out
+	l.options = opt
+	l.extFormatter = nil
+	// the old writers are closed by Close
+	wasClosed := l.closed
+	l.closed = false
+	l.mu.Unlock()
+	out.start(l)
+
+	// named loggers are created from the base logger, hold named.mu
+	l.named.mu.Lock()
+	l.Logger.SetReportCaller(opt.ReportCaller)
+	l.Logger.SetLevel(opt.Level)
+	l.named.levels = copyLevels(opt.NamedLevels)
+	for _, child := range l.named.loggers {
+		child.SetReportCaller(opt.ReportCaller)
+	}
+	l.refreshNamedLevels()
+	l.named.mu.Unlock()
+	if wasClosed {
+		return nil
+	}
+	return old.close()
+}
+
+func newOutputs(opt *Options) (*outputs, error) {
+	formatters := &formatterSet{opt: opt}
+	if _, err := formatters.index(opt.Formatter); err != nil {
+		return nil, err
+	}
+	extFormat, err := formatters.index(opt.ExtFormatter)
+	if err != nil {
+		return nil, err
+	}
 	// check log base dir
 	if opt.BaseDir == "" {
 		return nil, errors.New("Must give a log file dir path.")
 	}
-	if _, err = os.Stat(opt.BaseDir); err != nil {
-		if os.IsNotExist(err) {
-			// try to create dir
-			if err := os.Mkdir(opt.BaseDir, 0755); err != nil {
-				return nil, errors.WithStack(err)
-			}
-		} else {
-			return nil, errors.WithStack(err)
-		}
-	}
-	prefix := opt.LogFilePrefix + "-"
-	if opt.LogFilePrefix == "" {
-		prefix = ""
-	}
-	// rotate max age
-	maxAge := 7 * 24 * time.Hour
-	if opt.RotateDuration > 0 {
-		maxAge = opt.RotateDuration
-	}
-	// combine log
-	cbFmt := opt.BaseDir + "/" + prefix + "combine-%Y%m%d.log"
-	cbWriter, err := rotatelogs.New(cbFmt,
-		rotatelogs.WithLinkName(opt.BaseDir+"/latest-combine-"+prefix+"log"),
-		rotatelogs.WithMaxAge(maxAge),
-		rotatelogs.WithRotationTime(24*time.Hour))
+	sampler, err := newSampler(opt.Sampling)
 	if err != nil {
-		return nil, errors.WithMessage(err, "rotate combine log error")
-	}
-	// error log
-	errorFmt := opt.BaseDir + "/" + prefix + "error-%Y%m%d.log"
-	errorWriter, err := rotatelogs.New(errorFmt,
-		rotatelogs.WithLinkName(opt.BaseDir+"/latest-error-"+prefix+"log"),
-		rotatelogs.WithMaxAge(maxAge),
-		rotatelogs.WithRotationTime(24*time.Hour))
+		return nil, err
+	}
+	perm, err := newFilePerm(opt)
 	if err != nil {
-		return nil, errors.WithMessage(err, "rotate error log error")
-	}
-	lfsMap := lfshook.WriterMap{
-		logrus.PanicLevel: io.MultiWriter(cbWriter, errorWriter),
-		logrus.FatalLevel: io.MultiWriter(cbWriter, errorWriter),
-		logrus.ErrorLevel: io.MultiWriter(cbWriter, errorWriter),
-		logrus.WarnLevel:  cbWriter,
-		logrus.InfoLevel:  cbWriter,
-		logrus.DebugLevel: cbWriter,
-		logrus.TraceLevel: cbWriter,
-	}
-	hook := lfshook.NewHook(lfsMap, &formatter.Formatter{TimeStampLayout: opt.CustomTimeLayout})
-	lc.AddHook(hook)
-	logger := &Logger{
-		Logger:         lc,
-		options:        opt,
-		logFileHandler: mutePipe,
-		logWriters:     []*rotatelogs.RotateLogs{cbWriter, errorWriter},
+		return nil, err
+	}
+	if err := perm.prepareDir(opt.BaseDir); err != nil {
+		return nil, err
+	}
+	files, err := newFileRouter(opt, formatters, perm)
+	if err != nil {
+		return nil, err
 	}
-	return logger, nil
+	sinks, err := newSinks(opt, files.fallback)
+	if err != nil {
+		_ = files.close()
+		return nil, err
+	}
+	return &outputs{
+		formatters: formatters.formatters,
+		extFormat:  extFormat,
+		ext:        extWriter(opt.ExtLoggerWriter),
+		files:      files,
+		fallback:   files.fallback,
+		sampler:    sampler,
+		sinks:      sinks,
+	}, nil
 }
 
-// Close ALL internal file writer handle
+// extWriter write to all writers, or discard if there is none
+func extWriter(writers []io.Writer) io.Writer {
+	switch len(writers) {
+	case 0:
+		return io.Discard
+	case 1:
+		return writers[0]
+	default:
+		return io.MultiWriter(writers...)
+	}
+}
+
+// Close sync and close ALL internal file writer handle, it's safe to call more than once.
+// Errors of all writers are returned together. Entries logged after Close go to
+// Options.FallbackWriter (default os.Stderr) instead of the closed files.
 func (l *Logger) Close() error {
-	if err := l.logFileHandler.Close(); err != nil {
-		return errors.WithStack(err)
+	// the summaries of the last sampling window are logged before the files are closed
+	l.mu.RLock()
+	out := l.out
+	l.mu.RUnlock()
+	out.sampler.stop()
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
 	}
-	for _, w := range l.logWriters {
-		if err := w.Close(); err != nil {
-			return errors.WithStack(err)
-		}
+	l.closed = true
+	out = l.out
+	// entries being written are finished, close out of the lock since the janitor may log
+	l.mu.Unlock()
+	return combineErrors(out.sync(), out.close())
+}
+
+// Sync commit the content of the log files being written to disk
+func (l *Logger) Sync() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return nil
+	}
+	return l.out.sync()
+}
+
+// Reopen close and open all log files again, call it after the files are moved by
+// external tool like logrotate, so the moved files are not held open
+func (l *Logger) Reopen() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed || l.out.files == nil {
+		return nil
+	}
+	return l.out.files.reopen()
+}
+
+// start the background work of the outputs
+func (o *outputs) start(l *Logger) {
+	if o.files != nil {
+		o.files.janitor.start(l.Warnf)
+	}
+	if o.sampler != nil {
+		o.sampler.start(l.Logger)
+	}
+}
+
+func (o *outputs) sync() error {
+	if o.files != nil {
+		return o.files.sync()
 	}
 	return nil
 }
+
+// close all log files and sinks, ExtLoggerWriter are not closed
+func (o *outputs) close() error {
+	o.sampler.stop()
+	err := closeSinks(o.sinks)
+	if o.files != nil {
+		return combineErrors(o.files.close(), err)
+	}
+	return err
+}
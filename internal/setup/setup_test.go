@@ -17,10 +17,15 @@ limitations under the License.
 package setup
 
 import (
+	"bytes"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -57,3 +62,182 @@ func TestNew(t *testing.T) {
 	err = os.RemoveAll("./test-logs")
 	assert.NoErrorf(t, err, "remove test log dir failed")
 }
+
+func TestLogger_Reconfigure(t *testing.T) {
+	const dir = "./test-reconfigure-logs"
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: dir, LogFilePrefix: "first"})
+	assert.Nil(t, err)
+
+	// log concurrently while the writers are swapped, no entry is lost
+	const workers, count = 4, 200
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < count; j++ {
+				l.Named("worker").Info("entry")
+			}
+		}()
+	}
+	assert.Nil(t, l.Reconfigure(&Options{Level: logrus.InfoLevel, BaseDir: dir, LogFilePrefix: "second"}))
+	wg.Wait()
+	assert.Nil(t, l.Close())
+
+	lines := 0
+	for _, name := range []string{"latest-combine-first-log", "latest-combine-second-log"} {
+		b, err := os.ReadFile(dir + "/" + name)
+		if err == nil {
+			lines += strings.Count(string(b), "\n")
+		}
+	}
+	assert.Equal(t, workers*count, lines)
+
+	// invalid options are rejected
+	assert.Error(t, l.Reconfigure(&Options{}))
+	assert.Nil(t, os.RemoveAll(dir))
+}
+
+func TestNewConsole(t *testing.T) {
+	var out bytes.Buffer
+	l := NewConsole(&out, logrus.InfoLevel)
+	l.Debug("hidden")
+	l.Error("to console")
+	assert.NotContains(t, out.String(), "hidden")
+	assert.Contains(t, out.String(), "[ERROR]to console")
+
+	// add log files later
+	const dir = "./test-console-logs"
+	assert.Nil(t, l.Reconfigure(&Options{Level: logrus.InfoLevel, BaseDir: dir, LogFilePrefix: "console"}))
+	l.Info("to file")
+	assert.FileExists(t, dir+"/latest-combine-console-log")
+	assert.Nil(t, l.Close())
+	assert.Nil(t, os.RemoveAll(dir))
+}
+
+func TestNew_Formatter(t *testing.T) {
+	const dir = "./test-formatter-logs"
+	var out bytes.Buffer
+	l, err := New(&Options{
+		Level:           logrus.InfoLevel,
+		BaseDir:         dir,
+		ExtLoggerWriter: []io.Writer{&out},
+		Formatter:       JSONFormatter,
+	})
+	assert.Nil(t, err)
+	l.Named("payments").Info("json entry")
+	assert.Contains(t, out.String(), `"msg":"json entry"`)
+	assert.Contains(t, out.String(), `"logger":"payments"`)
+	assert.Nil(t, l.Close())
+
+	_, err = New(&Options{BaseDir: dir, Formatter: "xml"})
+	assert.Error(t, err)
+	assert.Nil(t, os.RemoveAll(dir))
+}
+
+func TestLogger_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: dir, ExternalRotate: true})
+	assert.Nil(t, err)
+	l.Error("first")
+	assert.FileExists(t, dir+"/combine.log")
+	assert.FileExists(t, dir+"/error.log")
+	links, _ := filepath.Glob(dir + "/latest-*")
+	assert.Empty(t, links)
+
+	assert.Nil(t, os.Rename(dir+"/combine.log", dir+"/combine.log.1"))
+	assert.Nil(t, l.Reopen())
+	l.Info("second")
+	assert.Nil(t, l.Close())
+	b, err := os.ReadFile(dir + "/combine.log")
+	assert.Nil(t, err)
+	assert.Equal(t, "second", lastMessage(string(b)))
+	b, err = os.ReadFile(dir + "/combine.log.1")
+	assert.Nil(t, err)
+	assert.Equal(t, "first", lastMessage(string(b)))
+
+	// rotated names are not fixed
+	_, err = New(&Options{BaseDir: dir, ExternalRotate: true, FilePattern: "{name}-%Y.log"})
+	assert.Error(t, err)
+}
+
+func TestLogger_Close(t *testing.T) {
+	dir := t.TempDir()
+	var fallback lockedBuffer
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: dir, FallbackWriter: &fallback})
+	assert.Nil(t, err)
+	l.Error("before close")
+	assert.Nil(t, l.Sync())
+
+	// every file is closed even if some fail
+	for _, s := range l.out.files.sinks {
+		assert.Nil(t, s.writer.fh.Close())
+	}
+	err = l.Close()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "close combine log error")
+	assert.Contains(t, err.Error(), "close error log error")
+	for _, s := range l.out.files.sinks {
+		assert.Nil(t, s.writer.fh)
+	}
+	assert.Nil(t, l.Close())
+	assert.Nil(t, l.Sync())
+
+	l.Error("after close")
+	assert.Equal(t, "after close", lastMessage(fallback.String()))
+	b, err := os.ReadFile(dir + "/latest-combine-log")
+	assert.Nil(t, err)
+	assert.Equal(t, "before close", lastMessage(string(b)))
+
+	// reconfigure opens the files again
+	assert.Nil(t, l.Reconfigure(&Options{Level: logrus.InfoLevel, BaseDir: dir}))
+	l.Info("reconfigured")
+	assert.Nil(t, l.Close())
+	b, err = os.ReadFile(dir + "/latest-combine-log")
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "reconfigured")
+}
+
+// countFormatter count the entries formatted
+type countFormatter struct {
+	logrus.Formatter
+	n int32
+}
+
+func (f *countFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	atomic.AddInt32(&f.n, 1)
+	return f.Formatter.Format(entry)
+}
+
+func TestLogger_FormatOnce(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: dir, ExtLoggerWriter: []io.Writer{&out}})
+	assert.Nil(t, err)
+	f := &countFormatter{Formatter: l.out.formatters[0]}
+	l.out.formatters[0] = f
+
+	l.Error("to all")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&f.n))
+	combine, err := os.ReadFile(dir + "/latest-combine-log")
+	assert.Nil(t, err)
+	errorLog, err := os.ReadFile(dir + "/latest-error-log")
+	assert.Nil(t, err)
+	assert.Equal(t, out.String(), string(combine))
+	assert.Equal(t, out.String(), string(errorLog))
+
+	// formatting outside of logging writes nothing
+	s, err := l.WithField("k", "v").String()
+	assert.Nil(t, err)
+	assert.NotEmpty(t, s)
+	assert.Nil(t, l.Close())
+	combine, err = os.ReadFile(dir + "/latest-combine-log")
+	assert.Nil(t, err)
+	assert.Equal(t, out.String(), string(combine))
+
+	// without ExtLoggerWriter Out discards
+	l, err = New(&Options{Level: logrus.InfoLevel, BaseDir: dir})
+	assert.Nil(t, err)
+	assert.Equal(t, io.Discard, l.out.ext)
+	assert.Nil(t, l.Close())
+}
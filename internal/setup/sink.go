@@ -0,0 +1,255 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"fmt"
+	"github.com/gin-melodic/glog/internal/formatter"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// sinkDialTimeout, sinkWriteTimeout bound the time the sender waits for a log daemon
+	sinkDialTimeout  = 3 * time.Second
+	sinkWriteTimeout = time.Second
+	// sinkRedialInterval wait before dialing a log daemon again after a failure
+	sinkRedialInterval = time.Second
+	// sinkQueueSize entries waiting for the sender, more go to the fallback writer
+	sinkQueueSize = 1024
+)
+
+// entrySink a destination encoding the entries itself, like syslog and journald
+type entrySink interface {
+	write(entry *logrus.Entry)
+	close() error
+}
+
+// netSink a connection to a log daemon. Entries are queued and sent by a goroutine, so a slow
+// or unreachable daemon never blocks logging. The connection is dialed again after a failure,
+// the entries which can't be sent or queued go to the fallback writer.
+type netSink struct {
+	name    string
+	network string
+	address string
+	// octetCounting frame the messages by their length (RFC 6587) on stream networks
+	octetCounting bool
+	// text the readable line of a message written to the fallback writer
+	text      func(b []byte) []byte
+	fallback  *fallback
+	queue     chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+	// redialInterval wait before dialing again after a failure
+	redialInterval time.Duration
+	// conn, retryAt owned by the sender
+	conn    net.Conn
+	retryAt time.Time
+}
+
+// dialSink connect to the log daemon and start the sender, it fails if the daemon can't be
+// reached now
+func dialSink(name, network, address string, octetCounting bool, fb *fallback,
+	text func(b []byte) []byte) (*netSink, error) {
+	conn, err := net.DialTimeout(network, address, sinkDialTimeout)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "connect %s %s://%s error", name, network, address)
+	}
+	s := &netSink{
+		name:           name,
+		network:        network,
+		address:        address,
+		octetCounting:  octetCounting,
+		text:           text,
+		fallback:       fb,
+		queue:          make(chan []byte, sinkQueueSize),
+		done:           make(chan struct{}),
+		redialInterval: sinkRedialInterval,
+		conn:           conn,
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// send queue b, it never blocks
+func (s *netSink) send(b []byte) {
+	select {
+	case s.queue <- b:
+	default:
+		s.drop(b)
+	}
+}
+
+func (s *netSink) run() {
+	defer s.wg.Done()
+	for {
+		select {
+		case b := <-s.queue:
+			s.deliver(b)
+		case <-s.done:
+			// send the queued entries before closing
+			for {
+				select {
+				case b := <-s.queue:
+					s.deliver(b)
+				default:
+					if s.conn != nil {
+						_ = s.conn.Close()
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *netSink) deliver(b []byte) {
+	if s.conn == nil {
+		if time.Now().Before(s.retryAt) {
+			s.drop(b)
+			return
+		}
+		conn, err := net.DialTimeout(s.network, s.address, sinkDialTimeout)
+		if err != nil {
+			s.fail(err, b)
+			return
+		}
+		s.conn = conn
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(sinkWriteTimeout))
+	var err error
+	if s.octetCounting {
+		buffers := net.Buffers{strconv.AppendInt(nil, int64(len(b)), 10), []byte{' '}, b}
+		_, err = buffers.WriteTo(s.conn)
+	} else {
+		_, err = s.conn.Write(b)
+	}
+	if err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		s.fail(err, b)
+	}
+}
+
+func (s *netSink) fail(err error, b []byte) {
+	s.retryAt = time.Now().Add(s.redialInterval)
+	s.drop(b)
+	s.fallback.report(errors.WithMessagef(err, "[GINLOG]Write %s log error.", s.name))
+}
+
+// drop write b to the fallback writer
+func (s *netSink) drop(b []byte) {
+	s.fallback.write(s.text(b))
+}
+
+// close send the queued entries and close the connection, entries sent later are lost
+func (s *netSink) close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+	})
+	return nil
+}
+
+// sinkField a field of an entry as text
+type sinkField struct {
+	key   string
+	value string
+}
+
+// sinkFields the fields of the entry as text, typed fields in their order, then the other
+// fields of entry.Data by key
+func sinkFields(entry *logrus.Entry) []sinkField {
+	if len(entry.Data) == 0 {
+		return nil
+	}
+	var fields []sinkField
+	if fs, ok := entry.Data[formatter.FieldsKey].(formatter.Fields); ok {
+		for i := range fs {
+			fields = append(fields, sinkField{key: fs[i].Key, value: sinkValue(fs[i].Value())})
+		}
+	}
+	keys := make([]string, 0, len(entry.Data))
+	for k, v := range entry.Data {
+		if _, ok := v.(formatter.Fields); ok && k == formatter.FieldsKey {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fields = append(fields, sinkField{key: k, value: sinkValue(entry.Data[k])})
+	}
+	return fields
+}
+
+func sinkValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// appName default app name of syslog and journald, the name of the executable
+func appName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// newSinks create the syslog and journald sinks of opt
+func newSinks(opt *Options, fb *fallback) ([]entrySink, error) {
+	var sinks []entrySink
+	if opt.Syslog != nil {
+		s, err := newSyslogSink(opt.Syslog, fb)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if opt.Journald != nil {
+		s, err := newJournaldSink(opt.Journald, fb)
+		if err != nil {
+			_ = closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// closeSinks close all sinks, it goes on when one of them fails
+func closeSinks(sinks []entrySink) error {
+	var errs []error
+	for _, s := range sinks {
+		errs = append(errs, s.close())
+	}
+	return combineErrors(errs...)
+}
@@ -0,0 +1,209 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package setup
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Facility syslog facility of the entries
+type Facility int
+
+// Facilities of RFC 5424
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLpr
+	FacilityNews
+	FacilityUucp
+	FacilityCron
+	FacilityAuthpriv
+	FacilityFtp
+	FacilityNtp
+	FacilityAudit
+	FacilityAlert
+	FacilityClock
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+	"ntp", "audit", "alert", "clock", "local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+func (f Facility) String() string {
+	if f < 0 || int(f) >= len(facilityNames) {
+		return "facility(" + strconv.Itoa(int(f)) + ")"
+	}
+	return facilityNames[f]
+}
+
+// ParseFacility Get the facility of name like "local0" or "daemon"
+func ParseFacility(name string) (Facility, error) {
+	for i, n := range facilityNames {
+		if strings.EqualFold(n, name) {
+			return Facility(i), nil
+		}
+	}
+	return 0, errors.Errorf("unknown syslog facility %q", name)
+}
+
+// DefaultSyslogAddress the local syslog socket, used when SyslogOptions.Network is empty
+const DefaultSyslogAddress = "/dev/log"
+
+// SyslogOptions send the entries to syslog in RFC 5424 format
+type SyslogOptions struct {
+	// Network "unixgram", "unix", "udp" or "tcp", empty means the local syslog socket.
+	// Messages over stream networks (tcp, unix) are framed by octet counting (RFC 6587).
+	Network string
+	// Address like "127.0.0.1:514" or a socket path
+	Address string
+	// Facility of the entries, the zero value FacilityKern means FacilityUser,
+	// since kernel messages can't be sent by processes
+	Facility Facility
+	// AppName APP-NAME of the messages, default the name of the executable
+	AppName string
+}
+
+// syslogSink write the entries to syslog
+type syslogSink struct {
+	conn     *netSink
+	facility Facility
+	host     string
+	app      string
+	pid      string
+}
+
+func newSyslogSink(opt *SyslogOptions, fb *fallback) (*syslogSink, error) {
+	network, address := opt.Network, opt.Address
+	if network == "" {
+		network = "unixgram"
+		if address == "" {
+			address = DefaultSyslogAddress
+		}
+	}
+	facility := opt.Facility
+	if facility == FacilityKern {
+		facility = FacilityUser
+	}
+	if facility < 0 || int(facility) >= len(facilityNames) {
+		return nil, errors.Errorf("invalid syslog facility %d", facility)
+	}
+	app := opt.AppName
+	if app == "" {
+		app = appName()
+	}
+	stream := network == "unix" || strings.HasPrefix(network, "tcp")
+	conn, err := dialSink("syslog", network, address, stream, fb, func(b []byte) []byte {
+		return append(b, '\n')
+	})
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	return &syslogSink{
+		conn:     conn,
+		facility: facility,
+		host:     syslogHeaderField(host, 255),
+		app:      syslogHeaderField(app, 48),
+		pid:      strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+// syslogSeverity severity of RFC 5424 by level
+func syslogSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return 2 // critical
+	case logrus.ErrorLevel:
+		return 3
+	case logrus.WarnLevel:
+		return 4
+	case logrus.InfoLevel:
+		return 6
+	default:
+		return 7 // debug
+	}
+}
+
+// syslogHeaderField a header field of at most max printable ASCII characters, "-" if it's empty
+func syslogHeaderField(s string, max int) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < max; i++ {
+		if c := s[i]; c > ' ' && c < 0x7f {
+			b = append(b, c)
+		} else {
+			b = append(b, '_')
+		}
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+// format the entry as "<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID - - MSG",
+// MSG is the message followed by the fields like " k=v"
+func (s *syslogSink) format(entry *logrus.Entry) []byte {
+	b := make([]byte, 0, 128+len(entry.Message))
+	b = append(b, '<')
+	b = strconv.AppendInt(b, int64(s.facility)*8+int64(syslogSeverity(entry.Level)), 10)
+	b = append(b, ">1 "...)
+	b = entry.Time.AppendFormat(b, "2006-01-02T15:04:05.000000Z07:00")
+	b = append(b, ' ')
+	b = append(b, s.host...)
+	b = append(b, ' ')
+	b = append(b, s.app...)
+	b = append(b, ' ')
+	b = append(b, s.pid...)
+	b = append(b, " - - "...)
+	b = append(b, entry.Message...)
+	for _, f := range sinkFields(entry) {
+		b = append(b, ' ')
+		b = append(b, f.key...)
+		b = append(b, '=')
+		if strings.ContainsAny(f.value, " \"=\n") || f.value == "" {
+			b = strconv.AppendQuote(b, f.value)
+		} else {
+			b = append(b, f.value...)
+		}
+	}
+	return b
+}
+
+func (s *syslogSink) write(entry *logrus.Entry) {
+	s.conn.send(s.format(entry))
+}
+
+func (s *syslogSink) close() error {
+	return s.conn.close()
+}
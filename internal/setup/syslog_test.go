@@ -0,0 +1,175 @@
+package setup
+
+import (
+	"bufio"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_SyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer pc.Close()
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: t.TempDir(), Syslog: &SyslogOptions{
+		Network: "udp", Address: pc.LocalAddr().String(), Facility: FacilityLocal0, AppName: "api server",
+	}})
+	assert.Nil(t, err)
+	defer l.Close()
+
+	l.WithField("user", "alice").WithField("reason", "bad password").Error("login failed")
+	buf := make([]byte, 4096)
+	_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	assert.Nil(t, err)
+	// local0 * 8 + error
+	assert.Regexp(t, `^<131>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}\S+ \S+ api_server `+
+		strconv.Itoa(os.Getpid())+` - - login failed reason="bad password" user=alice$`, string(buf[:n]))
+}
+
+func TestLogger_SyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: t.TempDir(),
+		Syslog: &SyslogOptions{Network: "tcp", Address: ln.Addr().String()}})
+	assert.Nil(t, err)
+	defer l.Close()
+	conn, err := ln.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	l.Info("first\nline")
+	l.Warn("second")
+	// octet counting framing
+	r := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	var messages []string
+	for i := 0; i < 2; i++ {
+		size, err := r.ReadString(' ')
+		assert.Nil(t, err)
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		assert.Nil(t, err)
+		msg := make([]byte, n)
+		_, err = io.ReadFull(r, msg)
+		assert.Nil(t, err)
+		messages = append(messages, string(msg))
+	}
+	// user * 8 + info, and warning
+	assert.Regexp(t, `^<14>1 .* - - first\nline$`, messages[0])
+	assert.Regexp(t, `^<12>1 .* - - second$`, messages[1])
+}
+
+func TestNew_SyslogUnreachable(t *testing.T) {
+	_, err := New(&Options{Level: logrus.InfoLevel, BaseDir: t.TempDir(),
+		Syslog: &SyslogOptions{Network: "unixgram", Address: t.TempDir() + "/missing.sock"}})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "connect syslog")
+}
+
+func TestNetSink_Redial(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	reported := make(chan error, 10)
+	var dropped lockedBuffer
+	fb := &fallback{w: &dropped, onWriteError: func(err error) {
+		reported <- err
+	}}
+	s, err := dialSink("syslog", "tcp", ln.Addr().String(), false, fb, func(b []byte) []byte {
+		return append(b, '\n')
+	})
+	assert.Nil(t, err)
+	defer s.close()
+	s.redialInterval = 100 * time.Millisecond
+	conn, err := ln.Accept()
+	assert.Nil(t, err)
+	// the connection is broken, writes fail once the peer reset is seen
+	assert.Nil(t, conn.Close())
+	var failure error
+	for i := 0; i < 100 && failure == nil; i++ {
+		s.send([]byte("lost"))
+		select {
+		case failure = <-reported:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	assert.NotNil(t, failure)
+	assert.Contains(t, failure.Error(), "Write syslog log error")
+	// the failed entry goes to the fallback writer
+	assert.Eventually(t, func() bool {
+		return strings.Contains(dropped.String(), "lost\n")
+	}, time.Second, 10*time.Millisecond)
+
+	// dialed again after the redial interval
+	time.Sleep(150 * time.Millisecond)
+	s.send([]byte("again"))
+	conn, err = ln.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 5)
+	_, err = io.ReadFull(conn, b)
+	assert.Nil(t, err)
+	assert.Equal(t, "again", string(b))
+}
+
+func TestNetSink_QueueFull(t *testing.T) {
+	var dropped lockedBuffer
+	// no sender
+	s := &netSink{queue: make(chan []byte, 1), fallback: &fallback{w: &dropped}, text: func(b []byte) []byte {
+		return append(b, '\n')
+	}}
+	s.send([]byte("queued"))
+	s.send([]byte("dropped"))
+	assert.Equal(t, "dropped\n", dropped.String())
+	assert.Equal(t, "queued", string(<-s.queue))
+}
+
+func TestLogger_SyslogUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	var dropped lockedBuffer
+	l, err := New(&Options{Level: logrus.InfoLevel, BaseDir: t.TempDir(), FallbackWriter: &dropped,
+		Syslog: &SyslogOptions{Network: "tcp", Address: ln.Addr().String()}})
+	assert.Nil(t, err)
+	defer l.Close()
+	conn, err := ln.Accept()
+	assert.Nil(t, err)
+	// the server goes away
+	assert.Nil(t, conn.Close())
+	assert.Nil(t, ln.Close())
+	begin := time.Now()
+	for i := 0; i < 100; i++ {
+		l.Info("while down")
+	}
+	// logging doesn't wait for the daemon
+	assert.True(t, time.Since(begin) < time.Second)
+	assert.Eventually(t, func() bool {
+		return strings.Contains(dropped.String(), " - - while down\n")
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestParseFacility(t *testing.T) {
+	f, err := ParseFacility("LOCAL3")
+	assert.Nil(t, err)
+	assert.Equal(t, FacilityLocal3, f)
+	assert.Equal(t, "local3", f.String())
+	assert.Equal(t, "daemon", FacilityDaemon.String())
+	_, err = ParseFacility("local8")
+	assert.NotNil(t, err)
+}
+
+func TestSyslogHeaderField(t *testing.T) {
+	assert.Equal(t, "-", syslogHeaderField("", 48))
+	assert.Equal(t, "my_app", syslogHeaderField("my app", 48))
+	assert.Equal(t, "abc", syslogHeaderField("abcdef", 3))
+	assert.True(t, regexp.MustCompile(`^\S+$`).MatchString(syslogHeaderField("a\tb\x00c", 48)))
+}
@@ -0,0 +1,119 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package glog
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"sync"
+)
+
+// levelMu serialize level changes so the change log is never interleaved
+var levelMu sync.Mutex
+
+// SetLevel change min allow level of global logger at runtime,
+// it is safe for concurrent use and the change is logged.
+func SetLevel(level logrus.Level) {
+	l := ShareLogger()
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	old := l.GetLevel()
+	if old == level {
+		return
+	}
+	// log the change while the more verbose of both levels is applied
+	if level > old {
+		l.SetLevel(level)
+		l.Logf(announceLevel(level), "[GINLOG]Log level changed from %s to %s.", old, level)
+		return
+	}
+	l.Logf(announceLevel(old), "[GINLOG]Log level changed from %s to %s.", old, level)
+	l.SetLevel(level)
+}
+
+// announceLevel level of the logs about configuration changes: WarnLevel, or the applied
+// level if it filters warnings out (e.g. error), so they are never filtered
+func announceLevel(applied logrus.Level) logrus.Level {
+	if applied < logrus.WarnLevel {
+		return applied
+	}
+	return logrus.WarnLevel
+}
+
+// Level Get min allow level of global logger
+func Level() logrus.Level {
+	return ShareLogger().GetLevel()
+}
+
+type levelPayload struct {
+	Level *logrus.Level `json:"level"`
+}
+
+type errorPayload struct {
+	Error string `json:"error"`
+}
+
+// LevelHandler returns a http.Handler to get or change the global logger level at runtime.
+//
+// GET responds the current level like {"level":"info"}.
+// PUT changes the level, the new level is given by a JSON body like {"level":"debug"}
+// or by a form value "level", responds the new level.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		switch r.Method {
+		case http.MethodGet:
+			current := Level()
+			_ = enc.Encode(levelPayload{Level: &current})
+		case http.MethodPut:
+			level, err := requestLevel(r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = enc.Encode(errorPayload{Error: err.Error()})
+				return
+			}
+			SetLevel(level)
+			current := Level()
+			_ = enc.Encode(levelPayload{Level: &current})
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_ = enc.Encode(errorPayload{Error: "only GET and PUT are supported"})
+		}
+	})
+}
+
+func requestLevel(r *http.Request) (logrus.Level, error) {
+	if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		lvl := r.FormValue("level")
+		if lvl == "" {
+			return 0, errors.New("must give a level")
+		}
+		return logrus.ParseLevel(lvl)
+	}
+	var p levelPayload
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		return 0, errors.WithMessage(err, "malformed request body")
+	}
+	if p.Level == nil {
+		return 0, errors.New("must give a level")
+	}
+	return *p.Level, nil
+}
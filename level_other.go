@@ -0,0 +1,25 @@
+//go:build !unix
+
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package glog
+
+// HandleLevelSignals SIGUSR1 and SIGUSR2 are not available on this platform,
+// use SetLevel or LevelHandler instead.
+func HandleLevelSignals() (stop func()) {
+	return func() {}
+}
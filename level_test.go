@@ -0,0 +1,89 @@
+package glog
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func useTestLogger(t *testing.T, out io.Writer) {
+	const kOutputDir = "./level-log"
+	l, err := NewLoggerHandle(&LoggerOptions{
+		MinAllowLevel:   logrus.InfoLevel,
+		HighPerformance: true,
+		OutputDir:       kOutputDir,
+		FilePrefix:      "level",
+		ExtLoggerWriter: []io.Writer{out},
+	})
+	assert.Nil(t, err)
+	restore := ReplaceGlobal(l)
+	t.Cleanup(func() {
+		restore()
+		_ = l.Close()
+		_ = os.RemoveAll(kOutputDir)
+	})
+}
+
+func TestSetLevel(t *testing.T) {
+	var out bytes.Buffer
+	useTestLogger(t, &out)
+	assert.Equal(t, logrus.InfoLevel, Level())
+
+	SetLevel(logrus.DebugLevel)
+	assert.Equal(t, logrus.DebugLevel, Level())
+	assert.Contains(t, out.String(), "Log level changed from info to debug")
+	ShareLogger().Debug("debug is on")
+	assert.Contains(t, out.String(), "debug is on")
+
+	// the change is logged before the level goes down
+	SetLevel(logrus.ErrorLevel)
+	assert.Contains(t, out.String(), "Log level changed from debug to error")
+	out.Reset()
+	SetLevel(logrus.ErrorLevel)
+	assert.Empty(t, out.String())
+
+	// warnings are filtered by both levels, the change is logged at error
+	SetLevel(logrus.FatalLevel)
+	assert.Contains(t, out.String(), "[ERROR][GINLOG]Log level changed from error to fatal")
+	SetLevel(logrus.ErrorLevel)
+	assert.Contains(t, out.String(), "[ERROR][GINLOG]Log level changed from fatal to error")
+}
+
+func TestLevelHandler(t *testing.T) {
+	useTestLogger(t, io.Discard)
+	h := LevelHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/log/level", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":"info"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"debug"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"level":"debug"}`, rec.Body.String())
+	assert.Equal(t, logrus.DebugLevel, Level())
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(url.Values{"level": {"warn"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, logrus.WarnLevel, Level())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"verbose"}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, logrus.WarnLevel, Level())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/log/level", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
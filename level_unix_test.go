@@ -0,0 +1,23 @@
+//go:build unix
+
+package glog
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleLevelSignals(t *testing.T) {
+	useTestLogger(t, io.Discard)
+	stop := HandleLevelSignals()
+	defer stop()
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	assert.Eventually(t, func() bool { return Level() == logrus.DebugLevel }, time.Second, 10*time.Millisecond)
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	assert.Eventually(t, func() bool { return Level() == logrus.InfoLevel }, time.Second, 10*time.Millisecond)
+}
@@ -17,17 +17,92 @@ limitations under the License.
 package glog
 
 import (
-	"fmt"
+	"context"
+	"github.com/gin-melodic/glog/internal/formatter"
 	"github.com/gin-melodic/glog/internal/setup"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"io"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var globalOnce = sync.Once{}
-var sl *setup.Logger
+// globalMu serialize InitGlobalLogger, Reconfigure and ReplaceGlobal
+var globalMu sync.Mutex
+var global atomic.Pointer[setup.Logger]
+
+// defaultLogger used by ShareLogger until the global logger is initialized
+var defaultLogger = setup.NewConsole(os.Stderr, logrus.InfoLevel)
+var defaultWarnOnce sync.Once
+
+// MultilineMode decide how line breaks inside a log message are written
+type MultilineMode = formatter.MultilineMode
+
+const (
+	// MultilineKeep write line breaks as they are
+	MultilineKeep = formatter.MultilineKeep
+	// MultilineEscape replace line breaks with `\n`, one entry per line
+	MultilineEscape = formatter.MultilineEscape
+	// MultilineIndent start every continuation line with a marker
+	MultilineIndent = formatter.MultilineIndent
+)
+
+// Formatter names of LoggerOptions.Formatter
+const (
+	// TextFormatter one line text like "<time> [PID:1][main.go:10][INFO]message"
+	TextFormatter = setup.TextFormatter
+	// JSONFormatter one JSON object per line
+	JSONFormatter = setup.JSONFormatter
+)
+
+// Route send the entries it matches (by level, field or predicate) to a rotated log file
+type Route = setup.Route
+
+// Sampling limit repetitive entries by level and message, see LoggerOptions.Sampling
+type Sampling = setup.Sampling
+
+// SampleRate log the First entries of a sampling window, then every Thereafter-th entry
+type SampleRate = setup.SampleRate
+
+// SyslogOptions send the entries to syslog in RFC 5424 format, see LoggerOptions.Syslog
+type SyslogOptions = setup.SyslogOptions
+
+// JournaldOptions send the entries to systemd-journald, see LoggerOptions.Journald
+type JournaldOptions = setup.JournaldOptions
+
+// SyslogFacility facility of SyslogOptions, use ParseSyslogFacility to read it from a name like "local0"
+type SyslogFacility = setup.Facility
+
+// Syslog facilities
+const (
+	FacilityUser     = setup.FacilityUser
+	FacilityDaemon   = setup.FacilityDaemon
+	FacilityAuth     = setup.FacilityAuth
+	FacilityAuthpriv = setup.FacilityAuthpriv
+	FacilityLocal0   = setup.FacilityLocal0
+	FacilityLocal1   = setup.FacilityLocal1
+	FacilityLocal2   = setup.FacilityLocal2
+	FacilityLocal3   = setup.FacilityLocal3
+	FacilityLocal4   = setup.FacilityLocal4
+	FacilityLocal5   = setup.FacilityLocal5
+	FacilityLocal6   = setup.FacilityLocal6
+	FacilityLocal7   = setup.FacilityLocal7
+)
+
+// ParseSyslogFacility Get the facility of name like "local0" or "daemon"
+func ParseSyslogFacility(name string) (SyslogFacility, error) {
+	return setup.ParseFacility(name)
+}
+
+// NoLink set LoggerOptions.LinkPattern or Route.LinkPattern to NoLink to disable the symlink
+const NoLink = setup.NoLink
+
+// DefaultRoutes all entries go to the combine log, and Error, Fatal, Panic entries go to the error log too
+func DefaultRoutes() []Route {
+	return setup.DefaultRoutes()
+}
 
 // LoggerOptions Init options
 type LoggerOptions struct {
@@ -43,54 +118,230 @@ type LoggerOptions struct {
 	// like os.Stdout in dev. Default write to logFile
 	ExtLoggerWriter  []io.Writer
 	CustomTimeLayout string
+	// Multiline how line breaks in message (SQL, panic dumps, pretty JSON) are written,
+	// default keep them as they are. Errors created with github.com/pkg/errors and
+	// logged with WithError print their stack trace in a delimited block.
+	Multiline MultilineMode
+	// ContinuationMarker prefix of continuation lines in MultilineIndent mode
+	ContinuationMarker string
+	// NamedLevels level overrides of named loggers (see Named) by name pattern,
+	// e.g. {"payments": DebugLevel, "gorm": WarnLevel, "*": InfoLevel}.
+	// Use ParseNamedLevels to read them from "payments=debug,gorm=warn,*=info".
+	// Named loggers without matched pattern follow MinAllowLevel.
+	NamedLevels map[string]logrus.Level
+	// Formatter TextFormatter or JSONFormatter, default is TextFormatter.
+	// Route.Formatter overrides it for one log file.
+	Formatter string
+	// ExtFormatter formatter of ExtLoggerWriter, default is Formatter, e.g. text on console
+	// and JSON in files. Every entry is encoded once per distinct formatter.
+	ExtFormatter string
+	// Routes decide which entries go to which log file, default is DefaultRoutes.
+	// e.g. keep warnings in their own file and audit entries for 180 days:
+	//
+	//	append(glog.DefaultRoutes(),
+	//		glog.Route{Name: "warn", Levels: []logrus.Level{logrus.WarnLevel}},
+	//		glog.Route{Name: "audit", Field: "audit", MaxAge: 180 * 24 * time.Hour})
+	//
+	// Every route has its own retention by age (default SaveDay), count and total size.
+	Routes []Route
+	// PurgeOrphans also remove log files of other prefixes (left by previous FilePrefix)
+	// out of the max age of the route with the same name.
+	// Don't enable it if loggers with different prefixes share OutputDir.
+	PurgeOrphans bool
+	// DirQuota limit total bytes of the log files, e.g. 5 << 30 for 5 GiB. When it's exceeded
+	// the oldest files are removed ahead of retention (never the files being written)
+	// and a warning is logged. 0 means no limit.
+	DirQuota int64
+	// FallbackWriter takes the entries when log files can't be written (disk full,
+	// read-only remount, ...), default os.Stderr. Failed files are re-opened with
+	// exponential backoff and a warning is logged into them when they recover.
+	FallbackWriter io.Writer
+	// OnWriteError called when a log file can't be written, e.g. to report degraded
+	// logging in health check. ShareLogger().Degraded() tells which files are failing.
+	// It's called on its own goroutine, one error at a time, and may log through the logger.
+	OnWriteError func(err error)
+	// DirMode, FileMode permission of OutputDir (and its missing parents) and the log files,
+	// e.g. 0750 and 0640. Default 0755 and 0644 with umask applied.
+	DirMode  os.FileMode
+	FileMode os.FileMode
+	// Owner, Group of OutputDir and the log files, user/group name or numeric id.
+	// Default unchanged, changing them usually requires root.
+	Owner string
+	Group string
+	// FilePattern strftime pattern of the log file names relative to OutputDir, default
+	// "{prefix}-{name}-%Y%m%d.log". Placeholders: {prefix} FilePrefix, {name} route name,
+	// {host} host name and {pid} process id, e.g. "{name}-{host}-{pid}-%Y%m%d.log" lets
+	// several instances share OutputDir. Route.FilePattern overrides it for one route.
+	FilePattern string
+	// LinkPattern name of the symlinks to the current log files with the same placeholders,
+	// default "latest-{name}-{prefix}-log", NoLink disables them
+	LinkPattern string
+	// ExternalRotate leave rotation to external tool like logrotate: log files have fixed
+	// names, default "{prefix}-{name}.log" without symlink, glog never removes them (SaveDay,
+	// route retention and DirQuota are not applied), and they are re-opened on SIGHUP (see
+	// HandleReopenSignal) or ShareLogger().Reopen().
+	ExternalRotate bool
+	// CaptureStderr redirect stderr of the process (unix only) to "<prefix>-crash.log" in
+	// OutputDir, so crash reports of unrecovered panics are kept. Entries written to os.Stderr,
+	// like the default FallbackWriter, go there too. Only applied when the global logger
+	// is initialized.
+	CaptureStderr bool
+	// Sampling limit repetitive entries, like a flapping dependency logging the same error
+	// thousands of times per second. e.g. log the first 10 errors with the same message per
+	// second, then every 100th, and a summary "suppressed K similar entries" per second:
+	//
+	//	&glog.Sampling{Levels: map[logrus.Level]glog.SampleRate{
+	//		logrus.ErrorLevel: {First: 10, Thereafter: 100}}}
+	//
+	// Levels not in it are not sampled, nil means no sampling.
+	Sampling *Sampling
+	// DedupTimeout collapse identical consecutive entries (same level, message and fields)
+	// of a log file, like syslog: the repeats within DedupTimeout of the first entry are
+	// replaced by a line "last message repeated N times", written when a different entry
+	// arrives or the timeout expires. ExtLoggerWriter is not collapsed. 0 means no collapse.
+	DedupTimeout time.Duration
+	// Syslog send the entries to syslog too, for hosts relying on rsyslog without a log
+	// shipper, e.g. {Network: "udp", Address: "10.0.0.5:514", Facility: FacilityLocal0}.
+	// An empty Network means the local socket /dev/log. nil means no syslog.
+	Syslog *SyslogOptions
+	// Journald send the entries to systemd-journald too, fields become journal fields like
+	// USER_ID. nil means no journald.
+	//
+	// Init fails if syslog or journald can't be reached. Later, entries are dropped while
+	// it's unreachable and OnWriteError reports the failure.
+	Journald *JournaldOptions
 }
 
 // InitGlobalLogger Module entry function
-// MUST call it before ShareLogger
-func InitGlobalLogger(opt *LoggerOptions) (initErr error) {
-	globalOnce.Do(func() {
-		if sl != nil {
-			return
-		}
-		l, err := setup.New(&setup.Options{
-			BaseDir:          opt.OutputDir,
-			Level:            opt.MinAllowLevel,
-			ReportCaller:     !opt.HighPerformance,
-			LogFilePrefix:    opt.FilePrefix,
-			RotateDuration:   opt.SaveDay * 24 * time.Hour,
-			ExtLoggerWriter:  opt.ExtLoggerWriter,
-			CustomTimeLayout: opt.CustomTimeLayout,
-		})
-		if err != nil {
-			initErr = errors.WithMessage(err, "[GINLOG]Init error.")
-			return
+// Call it before ShareLogger, otherwise entries go to stderr. Calls after the global logger is initialized do nothing,
+// use Reconfigure to change it. If the init fails the error is returned and the next call tries again.
+func InitGlobalLogger(opt *LoggerOptions) error {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	if global.Load() != nil {
+		return nil
+	}
+	return initGlobal(opt)
+}
+
+// initGlobal MUST hold globalMu
+func initGlobal(opt *LoggerOptions) error {
+	l, err := setup.New(opt.setupOptions())
+	if err != nil {
+		return errors.WithMessage(err, "[GINLOG]Init error.")
+	}
+	if opt.CaptureStderr {
+		if err := captureStderr(opt); err != nil {
+			_ = l.Close()
+			return errors.WithMessage(err, "[GINLOG]Init error.")
 		}
-		sl = l
-	})
-	return
+	}
+	global.Store(l)
+	return nil
+}
+
+// Reconfigure apply opt to the global logger, new writers are swapped in atomically
+// and entries being written are not lost. The global logger keeps the old configuration
+// when it returns an error. It initializes the global logger if not yet.
+func Reconfigure(opt *LoggerOptions) error {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	l := global.Load()
+	if l == nil {
+		return initGlobal(opt)
+	}
+	if err := l.Reconfigure(opt.setupOptions()); err != nil {
+		return errors.WithMessage(err, "[GINLOG]Reconfigure error.")
+	}
+	return nil
+}
+
+// ReplaceGlobal replace the global logger with logger and returns a function to restore
+// the previous one. ReplaceGlobal(nil) resets the global logger to uninitialized,
+// the default logger is used until next InitGlobalLogger, which is useful in tests.
+func ReplaceGlobal(logger *setup.Logger) (restore func()) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	prev := global.Swap(logger)
+	return func() {
+		ReplaceGlobal(prev)
+	}
 }
 
-// ShareLogger Get global logger handle
-// MUST InitGlobalLogger before call it
+// ShareLogger Get global logger handle, it never returns nil.
+// Before InitGlobalLogger succeeds, a default logger writing to stderr at InfoLevel is
+// returned, so entries logged before init are not lost. Don't keep the handle (or entries
+// created from it, like Named) across InitGlobalLogger, get it when logging.
 func ShareLogger() *setup.Logger {
-	if sl == nil {
-		fmt.Println("[GINLOG]Please call InitGlobalLogger first.")
+	l := global.Load()
+	if l == nil {
+		defaultWarnOnce.Do(func() {
+			defaultLogger.Warn("[GINLOG]Please call InitGlobalLogger first, logging to stderr now.")
+		})
+		return defaultLogger
+	}
+	return l
+}
+
+// Shutdown sync and close the log files of the global logger before the process exits,
+// entries logged after it go to LoggerOptions.FallbackWriter (default os.Stderr).
+// It returns ctx.Err() if ctx is done before the files are closed.
+func Shutdown(ctx context.Context) error {
+	l := global.Load()
+	if l == nil {
 		return nil
 	}
-	return sl
+	done := make(chan error, 1)
+	go func() {
+		done <- l.Close()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return errors.WithMessage(err, "[GINLOG]Shutdown error.")
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // NewLoggerHandle Sometimes, when you need a log instance to print some
 // specific log to a file, this method can provide that functionality
 func NewLoggerHandle(opt *LoggerOptions) (logger *setup.Logger, err error) {
-	logger, err = setup.New(&setup.Options{
-		BaseDir:          opt.OutputDir,
-		Level:            opt.MinAllowLevel,
-		ReportCaller:     !opt.HighPerformance,
-		LogFilePrefix:    opt.FilePrefix,
-		RotateDuration:   opt.SaveDay * 24 * time.Hour,
-		ExtLoggerWriter:  opt.ExtLoggerWriter,
-		CustomTimeLayout: opt.CustomTimeLayout,
-	})
+	logger, err = setup.New(opt.setupOptions())
 	return
 }
+
+func (opt *LoggerOptions) setupOptions() *setup.Options {
+	return &setup.Options{
+		BaseDir:            opt.OutputDir,
+		Level:              opt.MinAllowLevel,
+		ReportCaller:       !opt.HighPerformance,
+		LogFilePrefix:      opt.FilePrefix,
+		RotateDuration:     opt.SaveDay * 24 * time.Hour,
+		ExtLoggerWriter:    opt.ExtLoggerWriter,
+		CustomTimeLayout:   opt.CustomTimeLayout,
+		Multiline:          opt.Multiline,
+		ContinuationMarker: opt.ContinuationMarker,
+		NamedLevels:        opt.NamedLevels,
+		Formatter:          opt.Formatter,
+		ExtFormatter:       opt.ExtFormatter,
+		Routes:             opt.Routes,
+		PurgeOrphans:       opt.PurgeOrphans,
+		DirQuota:           opt.DirQuota,
+		FallbackWriter:     opt.FallbackWriter,
+		OnWriteError:       opt.OnWriteError,
+		DirMode:            opt.DirMode,
+		FileMode:           opt.FileMode,
+		Owner:              opt.Owner,
+		Group:              opt.Group,
+		FilePattern:        opt.FilePattern,
+		LinkPattern:        opt.LinkPattern,
+		ExternalRotate:     opt.ExternalRotate,
+		Sampling:           opt.Sampling,
+		DedupTimeout:       opt.DedupTimeout,
+		Syslog:             opt.Syslog,
+		Journald:           opt.Journald,
+	}
+}
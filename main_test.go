@@ -1,6 +1,8 @@
 package glog
 
 import (
+	"bytes"
+	"context"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"io"
@@ -9,6 +11,7 @@ import (
 )
 
 func TestInitGlobalLogger(t *testing.T) {
+	defer ReplaceGlobal(nil)()
 	err := InitGlobalLogger(&LoggerOptions{
 		MinAllowLevel:    logrus.DebugLevel,
 		HighPerformance:  false,
@@ -20,13 +23,14 @@ func TestInitGlobalLogger(t *testing.T) {
 	})
 	assert.Nil(t, err)
 	assert.DirExists(t, "./test-log")
-	assert.NotNil(t, sl)
+	assert.NotNil(t, global.Load())
 	assert.NoErrorf(t, ShareLogger().Close(), "close logger failed")
 	assert.NoErrorf(t, os.RemoveAll("./test-log"), "remove test log dir failed")
 }
 
 // Usage Example
 func TestUsageExample(t *testing.T) {
+	defer ReplaceGlobal(nil)()
 	// Must init before use logger
 	const kOutputDir = "./test-log"
 	err := InitGlobalLogger(&LoggerOptions{
@@ -59,5 +63,128 @@ func TestUsageExample(t *testing.T) {
 	// for middleware usage see test cases in specific modules.
 
 	// You can commit next line to see the logging files.
+	_ = partnerLogger.Close()
+	_ = ShareLogger().Close()
 	_ = os.RemoveAll(kOutputDir)
 }
+
+func TestInitGlobalLoggerRetry(t *testing.T) {
+	defer ReplaceGlobal(nil)()
+	const kOutputDir = "./test-retry-log"
+	// the failed init is reported and doesn't consume the init
+	err := InitGlobalLogger(&LoggerOptions{})
+	assert.Error(t, err)
+	assert.Nil(t, global.Load())
+
+	err = InitGlobalLogger(&LoggerOptions{OutputDir: kOutputDir, MinAllowLevel: logrus.InfoLevel})
+	assert.Nil(t, err)
+	l := global.Load()
+	assert.NotNil(t, l)
+	// later calls do nothing
+	err = InitGlobalLogger(&LoggerOptions{OutputDir: kOutputDir, MinAllowLevel: logrus.DebugLevel})
+	assert.Nil(t, err)
+	assert.Same(t, l, ShareLogger())
+	assert.Equal(t, logrus.InfoLevel, Level())
+
+	assert.Nil(t, ShareLogger().Close())
+	assert.Nil(t, os.RemoveAll(kOutputDir))
+}
+
+func TestReconfigure(t *testing.T) {
+	defer ReplaceGlobal(nil)()
+	const kOutputDir = "./test-reconfigure-log"
+	var first, second bytes.Buffer
+	assert.Nil(t, Reconfigure(&LoggerOptions{
+		MinAllowLevel:   logrus.InfoLevel,
+		OutputDir:       kOutputDir,
+		FilePrefix:      "first",
+		ExtLoggerWriter: []io.Writer{&first},
+	}))
+	l := ShareLogger()
+	payments := Named("payments")
+	l.Info("to first")
+
+	assert.Nil(t, Reconfigure(&LoggerOptions{
+		MinAllowLevel:   logrus.DebugLevel,
+		OutputDir:       kOutputDir,
+		FilePrefix:      "second",
+		ExtLoggerWriter: []io.Writer{&second},
+	}))
+	assert.Same(t, l, ShareLogger())
+	l.Debug("to second")
+	payments.Debug("payments to second")
+	assert.Contains(t, first.String(), "to first")
+	assert.NotContains(t, first.String(), "to second")
+	assert.Contains(t, second.String(), "[DEBUG]to second")
+	assert.Contains(t, second.String(), "[payments]payments to second")
+	assert.FileExists(t, kOutputDir+"/latest-combine-first-log")
+	assert.FileExists(t, kOutputDir+"/latest-combine-second-log")
+
+	// invalid options keep the old configuration
+	assert.Error(t, Reconfigure(&LoggerOptions{}))
+	l.Debug("still second")
+	assert.Contains(t, second.String(), "still second")
+
+	assert.Nil(t, l.Close())
+	assert.Nil(t, os.RemoveAll(kOutputDir))
+}
+
+func TestReplaceGlobal(t *testing.T) {
+	l, err := NewLoggerHandle(&LoggerOptions{OutputDir: "./test-replace-log"})
+	assert.Nil(t, err)
+	prev := global.Load()
+	restore := ReplaceGlobal(l)
+	assert.Same(t, l, ShareLogger())
+	restore()
+	assert.Same(t, prev, global.Load())
+	assert.Nil(t, l.Close())
+	assert.Nil(t, os.RemoveAll("./test-replace-log"))
+}
+
+func TestShareLoggerBeforeInit(t *testing.T) {
+	defer ReplaceGlobal(nil)()
+	// the default logger is used before init
+	assert.Same(t, defaultLogger, ShareLogger())
+	assert.NotPanics(t, func() {
+		ShareLogger().Info("before init")
+		Named("payments").Info("before init")
+	})
+
+	const kOutputDir = "./test-default-log"
+	assert.Nil(t, InitGlobalLogger(&LoggerOptions{OutputDir: kOutputDir}))
+	assert.False(t, defaultLogger == ShareLogger())
+	assert.Nil(t, ShareLogger().Close())
+	assert.Nil(t, os.RemoveAll(kOutputDir))
+}
+
+func TestShutdown(t *testing.T) {
+	defer ReplaceGlobal(nil)()
+	assert.Nil(t, Shutdown(context.Background()))
+
+	dir := t.TempDir()
+	var fallback bytes.Buffer
+	assert.Nil(t, InitGlobalLogger(&LoggerOptions{
+		MinAllowLevel:  logrus.InfoLevel,
+		OutputDir:      dir,
+		FallbackWriter: &fallback,
+	}))
+	ShareLogger().Info("before shutdown")
+	assert.Nil(t, Shutdown(context.Background()))
+	assert.Nil(t, Shutdown(context.Background()))
+	ShareLogger().Info("after shutdown")
+	assert.Contains(t, fallback.String(), "after shutdown")
+	b, err := os.ReadFile(dir + "/latest-combine-log")
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "before shutdown")
+	assert.NotContains(t, string(b), "after shutdown")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l, err := NewLoggerHandle(&LoggerOptions{OutputDir: dir})
+	assert.Nil(t, err)
+	ReplaceGlobal(l)
+	// either closed or canceled first
+	if err := Shutdown(ctx); err != nil {
+		assert.Equal(t, context.Canceled, err)
+	}
+}
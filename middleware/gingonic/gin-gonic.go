@@ -33,6 +33,10 @@ import (
 	"time"
 )
 
+// LoggerName name of the glog named logger used by the middleware,
+// tune it by LoggerOptions.NamedLevels, e.g. "gin=warn"
+const LoggerName = "gin"
+
 // Options The options of the common middleware
 type Options struct {
 	// BodyMaxSize Limit max characters of request body, default is 500
@@ -94,7 +98,8 @@ func InjectLogger(options *Options) gin.HandlerFunc {
 			requestExtInfo = options.CustomRequest(c.Request) + " |"
 		}
 		// output request
-		glog.ShareLogger().Infof("REQ -> | %15s | %s %s | %s | %s %s", c.ClientIP(), c.Request.Method,
+		logger := glog.Named(LoggerName)
+		logger.Infof("REQ -> | %15s | %s %s | %s | %s %s", c.ClientIP(), c.Request.Method,
 			path, query, requestExtInfo, body)
 
 		// parse response
@@ -118,9 +123,9 @@ func InjectLogger(options *Options) gin.HandlerFunc {
 			responseExtInfo = options.CustomResponseWriter(c.Writer) + " |"
 		}
 		// output response
-		logFunc := glog.ShareLogger().Infof
+		logFunc := logger.Infof
 		if c.Writer.Status() > http.StatusBadRequest {
-			logFunc = glog.ShareLogger().Errorf
+			logFunc = logger.Errorf
 		}
 		logFunc("<- RESP | %15s | %3d | %13v | %s %s | %s %s", c.ClientIP(), c.Writer.Status(),
 			excuteDurtion, c.Request.Method, path, responseExtInfo, respBody)
@@ -41,6 +41,10 @@ import (
 	"time"
 )
 
+// LoggerName name of the glog named logger used by the middleware,
+// tune it by LoggerOptions.NamedLevels, e.g. "gorm=warn"
+const LoggerName = "gorm"
+
 type Options struct {
 	SlowThreshold             time.Duration
 	SourceField               string
@@ -62,20 +66,21 @@ func (l *sqlLogger) LogMode(logger.LogLevel) logger.Interface {
 }
 
 func (l *sqlLogger) Info(ctx context.Context, msg string, data ...interface{}) {
-	glog.ShareLogger().WithContext(ctx).Infof(msg, data...)
+	glog.Named(LoggerName).WithContext(ctx).Infof(msg, data...)
 }
 
 func (l *sqlLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
-	glog.ShareLogger().WithContext(ctx).Warnf(msg, data...)
+	glog.Named(LoggerName).WithContext(ctx).Warnf(msg, data...)
 }
 
 func (l *sqlLogger) Error(ctx context.Context, msg string, data ...interface{}) {
-	glog.ShareLogger().WithContext(ctx).Errorf(msg, data...)
+	glog.Named(LoggerName).WithContext(ctx).Errorf(msg, data...)
 }
 
 func (l *sqlLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
 	dt := time.Since(begin)
 	sql, _ := fc()
+	log := glog.Named(LoggerName).WithContext(ctx)
 	f := logrus.Fields{}
 	// gorm source field config
 	if l.SourceField != "" {
@@ -84,14 +89,14 @@ func (l *sqlLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql s
 	// throw error, ignore empty result error
 	if err != nil && !(errors.Is(err, gorm.ErrRecordNotFound) && l.IgnoreRecordNotFoundError) {
 		f[logrus.ErrorKey] = err
-		glog.ShareLogger().WithContext(ctx).WithFields(f).Errorf("[SQL Error][cost %s] %s", dt, sql)
+		log.WithFields(f).Errorf("[SQL Error][cost %s] %s", dt, sql)
 		return
 	}
 	// check slow threshold
 	if l.SlowThreshold > 0 && dt > l.SlowThreshold {
-		glog.ShareLogger().WithContext(ctx).WithFields(f).Warnf("[Slow SQL][cost %s] %s", dt, sql)
+		log.WithFields(f).Warnf("[Slow SQL][cost %s] %s", dt, sql)
 		return
 	}
 	// debug mode
-	glog.ShareLogger().WithContext(ctx).WithFields(f).Debugf("[SQL][cost %s] %s", dt, sql)
+	log.WithFields(f).Debugf("[SQL][cost %s] %s", dt, sql)
 }
@@ -0,0 +1,80 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package glog
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+)
+
+// Named Get a sub logger of the global logger, e.g. glog.Named("payments").
+// Entries are tagged with the name and filtered by the level matched
+// in LoggerOptions.NamedLevels, so every module can be tuned independently.
+func Named(name string) *logrus.Entry {
+	return ShareLogger().Named(name)
+}
+
+// SetNamedLevels replace per name levels of the global logger at runtime
+func SetNamedLevels(levels map[string]logrus.Level) {
+	l := ShareLogger()
+	l.SetNamedLevels(levels)
+	l.Logf(announceLevel(l.GetLevel()), "[GINLOG]Named log levels changed to %s.", FormatNamedLevels(levels))
+}
+
+// ParseNamedLevels parse per name levels from spec like "payments=debug,gorm=warn,*=info".
+// A name may be a path.Match pattern like "payments.*", "*" matches all the others.
+func ParseNamedLevels(spec string) (map[string]logrus.Level, error) {
+	levels := map[string]logrus.Level{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || name == "" {
+			return nil, errors.Errorf("invalid named level %q, want name=level", item)
+		}
+		level, err := logrus.ParseLevel(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid named level %q", item)
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
+
+// FormatNamedLevels the reverse of ParseNamedLevels, names are sorted
+func FormatNamedLevels(levels map[string]logrus.Level) string {
+	names := make([]string, 0, len(levels))
+	for name := range levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.WriteString(levels[name].String())
+	}
+	return sb.String()
+}
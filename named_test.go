@@ -0,0 +1,39 @@
+package glog
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseNamedLevels(t *testing.T) {
+	levels, err := ParseNamedLevels(" payments=debug, gorm = warn,*=info,")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]logrus.Level{
+		"payments": logrus.DebugLevel,
+		"gorm":     logrus.WarnLevel,
+		"*":        logrus.InfoLevel,
+	}, levels)
+	assert.Equal(t, "*=info,gorm=warning,payments=debug", FormatNamedLevels(levels))
+
+	_, err = ParseNamedLevels("payments")
+	assert.Error(t, err)
+	_, err = ParseNamedLevels("payments=loud")
+	assert.Error(t, err)
+}
+
+func TestNamed(t *testing.T) {
+	var out bytes.Buffer
+	useTestLogger(t, &out)
+	SetNamedLevels(map[string]logrus.Level{"payments": logrus.DebugLevel})
+	Named("payments").Debug("charge")
+	Named("gin").Debug("request")
+	assert.Contains(t, out.String(), "[payments]charge")
+	assert.NotContains(t, out.String(), "request")
+
+	// the change is logged even if warnings are filtered out
+	SetLevel(logrus.ErrorLevel)
+	SetNamedLevels(map[string]logrus.Level{"gin": logrus.InfoLevel})
+	assert.Contains(t, out.String(), "[ERROR][GINLOG]Named log levels changed to gin=info.")
+}
@@ -0,0 +1,48 @@
+//go:build unix
+
+package glog
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleReopenSignal(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLoggerHandle(&LoggerOptions{
+		MinAllowLevel:  logrus.InfoLevel,
+		OutputDir:      dir,
+		FilePrefix:     "api",
+		ExternalRotate: true,
+	})
+	assert.Nil(t, err)
+	defer ReplaceGlobal(l)()
+	defer l.Close()
+	stop := HandleReopenSignal()
+	defer stop()
+
+	file := filepath.Join(dir, "api-combine.log")
+	l.Error("before rotation")
+	// like logrotate
+	assert.Nil(t, os.Rename(file, file+".1"))
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(file)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	l.Error("after rotation")
+
+	b, err := os.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "after rotation")
+	assert.NotContains(t, string(b), "before rotation")
+	b, err = os.ReadFile(file + ".1")
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "before rotation")
+	assert.NotContains(t, string(b), "after rotation")
+}
@@ -0,0 +1,169 @@
+//go:build go1.21
+
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package glog
+
+import (
+	"context"
+	"github.com/gin-melodic/glog/internal/formatter"
+	"github.com/gin-melodic/glog/internal/setup"
+	"github.com/sirupsen/logrus"
+	"log/slog"
+	"runtime"
+)
+
+// SlogHandler slog.Handler writing through a setup.Logger, so slog entries go to the same
+// log files, routes and formatters. Attributes become typed fields, keys in groups are
+// prefixed with the group names like "request.id".
+type SlogHandler struct {
+	// logger nil means the global logger when logging
+	logger *setup.Logger
+	// attrs given by WithAttrs, prefixed by their groups
+	attrs []Field
+	// prefix of the keys, the groups joined by "."
+	prefix string
+}
+
+// NewSlogHandler create a slog.Handler of logger, nil means the global logger (ShareLogger)
+func NewSlogHandler(logger *setup.Logger) *SlogHandler {
+	return &SlogHandler{logger: logger}
+}
+
+// SlogLogger Get a slog.Logger writing through the global logger, e.g. for slog based libraries:
+//
+//	slog.SetDefault(glog.SlogLogger())
+func SlogLogger() *slog.Logger {
+	return slog.New(NewSlogHandler(nil))
+}
+
+func (h *SlogHandler) target() *setup.Logger {
+	if h.logger != nil {
+		return h.logger
+	}
+	return ShareLogger()
+}
+
+// SlogLevel map a slog level to logrus level, levels between two slog levels
+// are mapped like the lower one, below slog.LevelDebug is TraceLevel
+func SlogLevel(level slog.Level) logrus.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return logrus.TraceLevel
+	case level < slog.LevelInfo:
+		return logrus.DebugLevel
+	case level < slog.LevelWarn:
+		return logrus.InfoLevel
+	case level < slog.LevelError:
+		return logrus.WarnLevel
+	default:
+		return logrus.ErrorLevel
+	}
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.target().IsLevelEnabled(SlogLevel(level))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := make([]Field, 0, len(h.attrs)+r.NumAttrs())
+	fields = append(fields, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+	rec := setup.Record{
+		Time:    r.Time,
+		Level:   SlogLevel(r.Level),
+		Message: r.Message,
+		Fields:  fields,
+		Context: ctx,
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		rec.Caller = &frame
+	}
+	h.target().LogRecord(&rec)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	c := *h
+	c.attrs = make([]Field, 0, len(h.attrs)+len(attrs))
+	c.attrs = append(c.attrs, h.attrs...)
+	for _, a := range attrs {
+		c.attrs = appendAttr(c.attrs, h.prefix, a)
+	}
+	return &c
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.prefix = h.prefix + name + "."
+	return &c
+}
+
+// appendAttr append a as typed fields, groups are flattened with their names as prefix
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		group := v.Group()
+		if len(group) == 0 {
+			return fields
+		}
+		// a group without key is inlined
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range group {
+			fields = appendAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+	// an empty attr is ignored
+	if a.Key == "" && v.Any() == nil {
+		return fields
+	}
+	key := prefix + a.Key
+	switch v.Kind() {
+	case slog.KindString:
+		return append(fields, String(key, v.String()))
+	case slog.KindInt64:
+		return append(fields, Int64(key, v.Int64()))
+	case slog.KindUint64:
+		return append(fields, Uint64(key, v.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, Float64(key, v.Float64()))
+	case slog.KindBool:
+		return append(fields, Bool(key, v.Bool()))
+	case slog.KindDuration:
+		return append(fields, Duration(key, v.Duration()))
+	case slog.KindTime:
+		return append(fields, Time(key, v.Time()))
+	default:
+		if err, ok := v.Any().(error); ok {
+			return append(fields, Field{Key: key, Type: formatter.ErrorType, Interface: err})
+		}
+		return append(fields, Any(key, v.Any()))
+	}
+}
@@ -0,0 +1,64 @@
+//go:build go1.21
+
+package glog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSlogLevel(t *testing.T) {
+	for level, want := range map[slog.Level]logrus.Level{
+		slog.LevelDebug - 4: logrus.TraceLevel,
+		slog.LevelDebug:     logrus.DebugLevel,
+		slog.LevelInfo:      logrus.InfoLevel,
+		slog.LevelInfo + 2:  logrus.InfoLevel,
+		slog.LevelWarn:      logrus.WarnLevel,
+		slog.LevelError:     logrus.ErrorLevel,
+		slog.LevelError + 4: logrus.ErrorLevel,
+	} {
+		assert.Equal(t, want, SlogLevel(level), level.String())
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	l, err := NewLoggerHandle(&LoggerOptions{
+		MinAllowLevel:   logrus.InfoLevel,
+		OutputDir:       dir,
+		ExtLoggerWriter: []io.Writer{&out},
+	})
+	assert.Nil(t, err)
+	defer ReplaceGlobal(l)()
+	defer l.Close()
+
+	logger := SlogLogger()
+	logger.Debug("hidden")
+	assert.False(t, logger.Enabled(context.Background(), slog.LevelDebug))
+	assert.Empty(t, out.String())
+
+	_, _, line, _ := runtime.Caller(0)
+	logger.With("service", "api").WithGroup("req").With("id", 7).Error("request failed",
+		slog.Duration("cost", 2*time.Second),
+		slog.Group("user", slog.String("name", "alice"), slog.Bool("vip", true)),
+		slog.Group("empty"),
+		slog.Any("err", errors.New("timeout")))
+	assert.Contains(t, out.String(), "[slog_test.go:"+strconv.Itoa(line+1)+"][ERROR]request failed "+
+		"service=api req.id=7 req.cost=2s req.user.name=alice req.user.vip=true req.err=timeout\n")
+
+	// routed to the error log too
+	b, err := os.ReadFile(dir + "/latest-error-log")
+	assert.Nil(t, err)
+	assert.Equal(t, out.String(), string(b))
+}
@@ -0,0 +1,88 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package glog
+
+import (
+	"bytes"
+	"github.com/gin-melodic/glog/internal/setup"
+	"github.com/sirupsen/logrus"
+	"log"
+	"runtime"
+	"strings"
+)
+
+// stdWriter Output of the log.Logger bridges, every line is an entry of the global logger
+type stdWriter struct {
+	level logrus.Level
+}
+
+func (w *stdWriter) Write(p []byte) (int, error) {
+	l := ShareLogger()
+	if !l.IsLevelEnabled(w.level) {
+		return len(p), nil
+	}
+	var caller *runtime.Frame
+	if l.ReportCaller {
+		caller = stdCaller()
+	}
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\r\n"), []byte{'\n'}) {
+		line = bytes.TrimRight(line, "\r")
+		if len(line) == 0 {
+			continue
+		}
+		l.LogRecord(&setup.Record{Level: w.level, Message: string(line), Caller: caller})
+	}
+	return len(p), nil
+}
+
+// stdCaller the first frame out of the log package and the bridge, it's where log.Printf is called
+func stdCaller() *runtime.Frame {
+	var pcs [32]uintptr
+	// skip runtime.Callers, stdCaller and stdWriter.Write
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "log.") && !strings.HasPrefix(frame.Function, "log/slog.") {
+			return &frame
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// StdLogger Get a standard library log.Logger writing to the global logger at level, for
+// libraries only accept it, like http.Server.ErrorLog. Every line written is an entry,
+// at PanicLevel and FatalLevel too it doesn't panic or exit.
+func StdLogger(level logrus.Level) *log.Logger {
+	return log.New(&stdWriter{level: level}, "", 0)
+}
+
+// RedirectStdLog redirect output of the log package (log.Printf, ...) to the global logger at
+// InfoLevel, every line is an entry. Call restore to write to the previous output again.
+func RedirectStdLog() (restore func()) {
+	flags, prefix, out := log.Flags(), log.Prefix(), log.Writer()
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(&stdWriter{level: logrus.InfoLevel})
+	return func() {
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+		log.SetOutput(out)
+	}
+}
@@ -0,0 +1,62 @@
+package glog
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func useStdTestLogger(t *testing.T) *bytes.Buffer {
+	var out bytes.Buffer
+	l, err := NewLoggerHandle(&LoggerOptions{
+		MinAllowLevel:   logrus.InfoLevel,
+		OutputDir:       t.TempDir(),
+		ExtLoggerWriter: []io.Writer{&out},
+	})
+	assert.Nil(t, err)
+	restore := ReplaceGlobal(l)
+	t.Cleanup(func() {
+		restore()
+		_ = l.Close()
+	})
+	return &out
+}
+
+func TestStdLogger(t *testing.T) {
+	out := useStdTestLogger(t)
+	StdLogger(logrus.DebugLevel).Print("hidden")
+	assert.Empty(t, out.String())
+
+	std := StdLogger(logrus.WarnLevel)
+	_, _, line, _ := runtime.Caller(0)
+	std.Printf("first line\nsecond line\r\n")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	caller := "[stdlog_test.go:" + strconv.Itoa(line+1) + "][WARNING]"
+	assert.Contains(t, lines[0], caller+"first line")
+	assert.Contains(t, lines[1], caller+"second line")
+}
+
+func TestStdLogger_Panic(t *testing.T) {
+	out := useStdTestLogger(t)
+	assert.NotPanics(t, func() {
+		StdLogger(logrus.PanicLevel).Print("http: panic serving")
+	})
+	assert.Contains(t, out.String(), "[PANIC]http: panic serving\n")
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	out := useStdTestLogger(t)
+	restore := RedirectStdLog()
+	_, _, line, _ := runtime.Caller(0)
+	log.Println("from std log")
+	restore()
+	assert.Contains(t, out.String(), "[stdlog_test.go:"+strconv.Itoa(line+1)+"][INFO]from std log\n")
+	assert.NotEqual(t, 0, log.Flags())
+}
@@ -0,0 +1,112 @@
+/**
+Copyright 2021 Gin Van
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package glog
+
+import (
+	"bytes"
+	"github.com/gin-melodic/glog/internal/setup"
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval poll interval of WatchConfig
+const DefaultWatchInterval = 5 * time.Second
+
+// WatchConfig poll the config file at path (see LoadOptions) every interval and apply the
+// changes (level, outputs, rotation, ...) to the running global logger without dropping entries.
+// Level changes are applied in place, the log files are only re-created when other options
+// change. Options the file can't express, like Routes, FallbackWriter and OnWriteError, are kept,
+// so is ExtLoggerWriter unless the file (or environment variable) sets outputs.
+// Every reload logs what changed; an invalid config is logged and rejected, the logger keeps
+// the old one. The file is expected to be loaded and applied already by InitGlobalLogger,
+// so only later changes are applied. Call stop to stop watching.
+func WatchConfig(path string, interval time.Duration) (stop func(), err error) {
+	l := global.Load()
+	if l == nil {
+		return nil, errors.New("[GINLOG]Init the global logger before watching its config.")
+	}
+	last, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b, err := os.ReadFile(path)
+				if err != nil {
+					// the file may be replaced right now, try it next time
+					continue
+				}
+				if bytes.Equal(b, last) {
+					continue
+				}
+				last = b
+				reloadConfig(l, path)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}, nil
+}
+
+// reloadConfig apply the config file at path to l
+func reloadConfig(l *setup.Logger, path string) {
+	opt, set, err := loadOptions(path)
+	if err != nil {
+		l.Errorf("[GINLOG]Reload config %s error, keep the old one. %v", path, err)
+		return
+	}
+	current := l.Options()
+	next := opt.setupOptions()
+	next.Routes = current.Routes
+	next.FallbackWriter = current.FallbackWriter
+	next.OnWriteError = current.OnWriteError
+	if !set["outputs"] {
+		// may be writers set in code
+		next.ExtLoggerWriter = current.ExtLoggerWriter
+	}
+	changes := current.Diff(next)
+	if len(changes) == 0 {
+		return
+	}
+	// only the levels changed, keep the outputs
+	outputs := *next
+	outputs.Level, outputs.NamedLevels = current.Level, current.NamedLevels
+	if len(current.Diff(&outputs)) == 0 {
+		l.SetLevels(next.Level, next.NamedLevels)
+	} else if err := l.Reconfigure(next); err != nil {
+		l.Errorf("[GINLOG]Reload config %s error, keep the old one. %v", path, err)
+		return
+	}
+	l.Logf(announceLevel(l.GetLevel()), "[GINLOG]Config %s reloaded: %s.", path, strings.Join(changes, ", "))
+}
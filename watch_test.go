@@ -0,0 +1,121 @@
+package glog
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer a buffer written by the watcher and read by the test
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchConfig(t *testing.T) {
+	defer ReplaceGlobal(nil)()
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	path := writeConfig(t, "glog.yaml", "level: info\nfile_prefix: watch\noutput_dir: "+logDir+"\n")
+	opt, err := LoadOptions(path)
+	assert.Nil(t, err)
+	assert.Nil(t, InitGlobalLogger(opt))
+	defer func() {
+		_ = ShareLogger().Close()
+	}()
+
+	stop, err := WatchConfig(path, 10*time.Millisecond)
+	assert.Nil(t, err)
+	defer stop()
+
+	combine := func() string {
+		b, _ := os.ReadFile(filepath.Join(logDir, "latest-combine-watch-log"))
+		return string(b)
+	}
+	// the log file being written is unlinked, it's not re-created by a level change
+	ShareLogger().Info("before level change")
+	current, err := filepath.EvalSymlinks(filepath.Join(logDir, "latest-combine-watch-log"))
+	assert.Nil(t, err)
+	assert.Nil(t, os.Remove(current))
+	assert.Nil(t, os.WriteFile(path, []byte("level: debug\nfile_prefix: watch\noutput_dir: "+logDir+"\n"), 0644))
+	assert.Eventually(t, func() bool { return Level() == logrus.DebugLevel }, time.Second, 10*time.Millisecond)
+	ShareLogger().Info("after level change")
+	_, err = os.Stat(current)
+	assert.True(t, os.IsNotExist(err))
+
+	// other changes re-create the outputs
+	assert.Nil(t, os.WriteFile(path, []byte("level: debug\nfile_prefix: watch\nsave_day: 3\noutput_dir: "+logDir+"\n"), 0644))
+	assert.Eventually(t, func() bool {
+		return strings.Contains(combine(), `reloaded: RotateDuration: "168h0m0s" -> "72h0m0s"`)
+	}, time.Second, 10*time.Millisecond)
+
+	// invalid config is rejected
+	assert.Nil(t, os.WriteFile(path, []byte("level: verbose\noutput_dir: "+logDir+"\n"), 0644))
+	assert.Eventually(t, func() bool {
+		return strings.Contains(combine(), "keep the old one")
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, logrus.DebugLevel, Level())
+
+	_, err = WatchConfig(filepath.Join(dir, "missing.yaml"), 0)
+	assert.Error(t, err)
+	defer ReplaceGlobal(nil)()
+	_, err = WatchConfig(path, 0)
+	assert.Error(t, err)
+}
+
+func TestWatchConfig_ExtLoggerWriter(t *testing.T) {
+	defer ReplaceGlobal(nil)()
+	logDir := filepath.Join(t.TempDir(), "logs")
+	path := writeConfig(t, "glog.yaml", "level: info\noutput_dir: "+logDir+"\n")
+	opt, err := LoadOptions(path)
+	assert.Nil(t, err)
+	// set in code, not by the file
+	var out lockedBuffer
+	opt.ExtLoggerWriter = []io.Writer{&out}
+	assert.Nil(t, InitGlobalLogger(opt))
+	defer func() {
+		_ = ShareLogger().Close()
+	}()
+	stop, err := WatchConfig(path, 10*time.Millisecond)
+	assert.Nil(t, err)
+	defer stop()
+
+	assert.Nil(t, os.WriteFile(path, []byte("level: debug\noutput_dir: "+logDir+"\n"), 0644))
+	assert.Eventually(t, func() bool { return Level() == logrus.DebugLevel }, time.Second, 10*time.Millisecond)
+	ShareLogger().Debug("still written")
+	assert.Contains(t, out.String(), "still written")
+
+	// the reload is logged even if warnings are filtered out
+	assert.Nil(t, os.WriteFile(path, []byte("level: error\nsave_day: 3\noutput_dir: "+logDir+"\n"), 0644))
+	assert.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "[ERROR][GINLOG]Config "+path+" reloaded")
+	}, time.Second, 10*time.Millisecond)
+
+	// the writers of outputs replace them
+	assert.Nil(t, os.WriteFile(path, []byte("level: error\nsave_day: 3\noutputs: stderr\noutput_dir: "+logDir+"\n"), 0644))
+	assert.Eventually(t, func() bool {
+		w := ShareLogger().Options().ExtLoggerWriter
+		return len(w) == 1 && w[0] == os.Stderr
+	}, time.Second, 10*time.Millisecond)
+	ShareLogger().Error("not written")
+	assert.NotContains(t, out.String(), "not written")
+}